@@ -1,3 +1,3 @@
-module main.go
+module simple_web_server
 
 go 1.22.3
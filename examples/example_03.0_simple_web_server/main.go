@@ -1,21 +1,42 @@
-
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"net/http"
 	"log"
+	"net/http"
+	"os"
+
+	"simple_web_server/server"
 )
 
 // In this example we are using a concurrent web server that can deal with multiple
 // requests at the same time. Run the server and open a browser to
 // http://localhost:8080/ to see the output.
+//
+// Unlike http.ListenAndServe the server package sets timeouts, adds request
+// IDs, logging and panic recovery, and shuts down gracefully on Ctrl-C.
+// Try `go run . -help` to see the settings (also taken from SERVER_* env vars).
 
 func handler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Hello World! from %s\n", r.URL.Path[1:])
 }
 
 func main() {
-	http.HandleFunc("/", handler)  // Bind the handler to the top-leve route
-	log.Fatal(http.ListenAndServe(":8080", nil)) // Start the server on port 8080
-}
\ No newline at end of file
+	cfg := server.DefaultConfig()
+
+	if err := cfg.FromEnv(os.LookupEnv); err != nil {
+		log.Fatal(err)
+	}
+
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	mux := http.NewServeMux()    // Our own mux instead of the global default one
+	mux.HandleFunc("/", handler) // Bind the handler to the top-level route
+
+	if err := server.New(cfg, mux, nil).Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
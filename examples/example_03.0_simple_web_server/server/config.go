@@ -0,0 +1,108 @@
+package server
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Config holds everything needed to run a Server. Every field can be set
+// from the environment (SERVER_ADDR, SERVER_READ_TIMEOUT, ...) and from the
+// command line (-addr, -read-timeout, ...), flags taking precedence.
+type Config struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // how long in-flight requests may take to drain
+	MaxHeaderBytes  int
+	TLSCertFile     string // serve HTTPS when both cert and key are set
+	TLSKeyFile      string
+}
+
+// DefaultConfig returns sensible values for a small service; unlike the
+// zero value of http.Server it never lets a slow client hold a connection forever.
+func DefaultConfig() Config {
+	return Config{
+		Addr:            ":8080",
+		ReadTimeout:     5 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     120 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+		MaxHeaderBytes:  1 << 20,
+	}
+}
+
+// FromEnv overrides the fields whose SERVER_* variable is set. Pass
+// os.LookupEnv as lookup (tests can pass a map lookup instead).
+func (c *Config) FromEnv(lookup func(string) (string, bool)) error {
+	strs := map[string]*string{
+		"SERVER_ADDR":          &c.Addr,
+		"SERVER_TLS_CERT_FILE": &c.TLSCertFile,
+		"SERVER_TLS_KEY_FILE":  &c.TLSKeyFile,
+	}
+
+	durations := map[string]*time.Duration{
+		"SERVER_READ_TIMEOUT":     &c.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":    &c.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":     &c.IdleTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT": &c.ShutdownTimeout,
+	}
+
+	for name, p := range strs {
+		if v, ok := lookup(name); ok {
+			*p = v
+		}
+	}
+
+	for name, p := range durations {
+		if v, ok := lookup(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*p = d
+		}
+	}
+
+	if v, ok := lookup("SERVER_MAX_HEADER_BYTES"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid SERVER_MAX_HEADER_BYTES: %w", err)
+		}
+		c.MaxHeaderBytes = n
+	}
+
+	return nil
+}
+
+// RegisterFlags binds the fields to flags in fs, using the current
+// values as defaults, so call it after FromEnv and before fs.Parse.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", c.Addr, "address to listen on")
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "maximum duration for reading a request")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "maximum duration for writing a response")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "how long keep-alive connections stay open")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long to wait for in-flight requests on shutdown")
+	fs.IntVar(&c.MaxHeaderBytes, "max-header-bytes", c.MaxHeaderBytes, "maximum size of request headers")
+	fs.StringVar(&c.TLSCertFile, "tls-cert", c.TLSCertFile, "TLS certificate file (enables HTTPS with -tls-key)")
+	fs.StringVar(&c.TLSKeyFile, "tls-key", c.TLSKeyFile, "TLS private key file")
+}
+
+// Validate reports configuration mistakes before we try to listen.
+func (c Config) Validate() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("both a TLS certificate and key are needed")
+	}
+
+	if c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 || c.ShutdownTimeout < 0 {
+		return fmt.Errorf("timeouts can't be negative")
+	}
+
+	if c.MaxHeaderBytes < 0 {
+		return fmt.Errorf("max header bytes can't be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package server runs an http.Handler the way a production service
+// should: with timeouts, request IDs, access logs, panic recovery and a
+// graceful shutdown on SIGINT / SIGTERM.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Server is an http.Server plus the middleware and shutdown logic.
+type Server struct {
+	cfg    Config
+	srv    *http.Server
+	logger *log.Logger
+}
+
+// New wraps h with the RequestID, Logging and Recover middlewares.
+// A nil logger logs to stderr.
+func New(cfg Config, h http.Handler, logger *log.Logger) *Server {
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	h = Chain(h, RequestID, Logging(logger), Recover(logger))
+
+	return &Server{
+		cfg:    cfg,
+		logger: logger,
+		srv: &http.Server{
+			Addr:           cfg.Addr,
+			Handler:        h,
+			ReadTimeout:    cfg.ReadTimeout,
+			WriteTimeout:   cfg.WriteTimeout,
+			IdleTimeout:    cfg.IdleTimeout,
+			MaxHeaderBytes: cfg.MaxHeaderBytes,
+			ErrorLog:       logger,
+		},
+	}
+}
+
+// Run listens on the configured address and serves until ctx is done or
+// the process gets SIGINT / SIGTERM, then drains in-flight requests.
+func (s *Server) Run(ctx context.Context) error {
+	// before listening, so a bad config doesn't even take the port
+	if err := s.cfg.Validate(); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve is like Run on an existing listener, which it closes when done,
+// or right away if the configuration isn't valid.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	if err := s.cfg.Validate(); err != nil {
+		ln.Close()
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+
+	go func() {
+		if s.cfg.TLSCertFile != "" {
+			errc <- s.srv.ServeTLS(ln, s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+		} else {
+			errc <- s.srv.Serve(ln)
+		}
+	}()
+
+	s.logger.Printf("listening on %s", ln.Addr())
+
+	select {
+	case err := <-errc:
+		// the server stopped by itself, so something went wrong
+		return err
+	case <-ctx.Done():
+	}
+
+	s.logger.Printf("shutting down, waiting up to %s for in-flight requests", s.cfg.ShutdownTimeout)
+
+	// a fresh context: the old one is already cancelled
+	sctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Shutdown stops accepting connections and waits for active ones to finish
+	if err := s.srv.Shutdown(sctx); err != nil {
+		s.srv.Close()
+		return err
+	}
+
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	s.logger.Print("server stopped")
+	return nil
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFromEnv(t *testing.T) {
+	env := map[string]string{
+		"SERVER_ADDR":             ":9090",
+		"SERVER_READ_TIMEOUT":     "2s",
+		"SERVER_MAX_HEADER_BYTES": "4096",
+	}
+
+	cfg := DefaultConfig()
+
+	err := cfg.FromEnv(func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Addr != ":9090" || cfg.ReadTimeout != 2*time.Second || cfg.MaxHeaderBytes != 4096 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	if cfg.WriteTimeout != DefaultConfig().WriteTimeout {
+		t.Errorf("write timeout changed to %s", cfg.WriteTimeout)
+	}
+
+	bad := func(string) (string, bool) { return "soon", true }
+
+	if err := cfg.FromEnv(bad); err == nil {
+		t.Error("invalid duration accepted")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+
+	logger := log.New(&logs, "", 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/id", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, RequestIDFrom(r.Context()))
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	h := New(DefaultConfig(), mux, logger).srv.Handler
+
+	// an ID sent by the client is kept
+	req := httptest.NewRequest("GET", "/id", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "abc" || rec.Header().Get(RequestIDHeader) != "abc" {
+		t.Errorf("request ID not propagated: %q", rec.Body.String())
+	}
+
+	// otherwise one is made up
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/id", nil))
+
+	if rec.Body.Len() == 0 || rec.Body.String() != rec.Header().Get(RequestIDHeader) {
+		t.Errorf("request ID not generated: %q", rec.Body.String())
+	}
+
+	// a panic becomes a 500 and gets logged
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d after panic", rec.Code)
+	}
+
+	if !strings.Contains(logs.String(), "panic: boom") || !strings.Contains(logs.String(), "GET /panic 500") {
+		t.Errorf("unexpected logs:\n%s", logs.String())
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := New(DefaultConfig(), slow, log.New(io.Discard, "", 0))
+
+	served := make(chan error, 1)
+
+	go func() {
+		served <- srv.Serve(ctx, ln)
+	}()
+
+	body := make(chan string, 1)
+
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel() // the request is in flight, it must still complete
+
+	if b := <-body; b != "done" {
+		t.Errorf("in-flight request got %q", b)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("serve: %v", err)
+	}
+}
+
+func TestServeValidates(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.TLSCertFile = "cert.pem" // but no key
+
+	if err := New(cfg, http.NotFoundHandler(), log.New(io.Discard, "", 0)).Serve(context.Background(), ln); err == nil {
+		t.Fatal("served with a bad config")
+	}
+
+	// and the listener is closed, as it would be after serving
+	if _, err := ln.Accept(); err == nil {
+		t.Error("listener still open")
+	}
+}
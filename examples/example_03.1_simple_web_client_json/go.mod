@@ -1,3 +1,7 @@
-module main.go
+module simple_web_client_json
 
 go 1.22.3
+
+require jsonplaceholder v0.0.0
+
+replace jsonplaceholder => ../example_03.3_jsonplaceholder_client
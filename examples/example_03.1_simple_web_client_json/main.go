@@ -1,51 +1,33 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
+
+	"jsonplaceholder"
 )
 
 // In this example we are using a simple web client that reads a json response
-// from a server and parses it into a struct
+// from a server and parses it into a struct.
+//
+// The request, status check and decoding are done by the jsonplaceholder
+// client (see example_03.3), which returns a typed Todo or an error.
+// Use -base http://localhost:8081 to read from the local fake server instead.
 
-const url = "https://jsonplaceholder.typicode.com"
+func main() {
+	base := flag.String("base", jsonplaceholder.DefaultBaseURL, "JSONPlaceholder base URL")
+	flag.Parse()
 
-type todo struct {
-	// userId	int    `json:"userId"`
-	Id        int    `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
-}
+	client := jsonplaceholder.NewClient(*base, nil)
 
-func main() {
-	resp, err := http.Get(url + "/todos/1")
+	my_todo, err := client.Todos.Get(context.Background(), 1)
 
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(-1)
 	}
 
-	defer resp.Body.Close() // Close so that the socket can be reused
-
-	if resp.StatusCode == http.StatusOK { // If 200
-		body, err := io.ReadAll(resp.Body) // Read the whole body of the response
-
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(-1)
-		}
-
-		// Unmarshal the json response into the struct
-		my_todo := todo{}
-		err = json.Unmarshal(body, &my_todo)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(-1)
-		}
-		fmt.Printf("%#v\n", my_todo)
-
-	}
+	fmt.Printf("%#v\n", my_todo)
 }
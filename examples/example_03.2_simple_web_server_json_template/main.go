@@ -1,70 +1,168 @@
 package main
 
 import (
-	"encoding/json"
-	"html/template"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
-	// "io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"jsonplaceholder"
+	"jsonplaceholder/cache"
+
+	"simple_web_server_json_template/outbound"
+)
+
+var (
+	client *jsonplaceholder.Client
+	pages  *renderer
 )
 
-var form = `
-<h1>Todo #{{.Id}}</h1>
-<div>{{printf "User %d" .UserId}}</div>
-<div>{{printf "%s (completed: %t)" .Title .Completed}}</div>
-`
-
-type todo struct {
-	UserId    int    `json:"userId"`
-	Id        int    `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
+type notFoundPage struct {
+	Error string `json:"error"`
+	Path  string `json:"path"`
 }
 
-func handler(w http.ResponseWriter, r *http.Request) {
-	const base = "https://jsonplaceholder.typicode.com/"
+type badRequestPage struct {
+	Error string `json:"error"`
+}
+
+// upstreamError turns a failed upstream call into the right response:
+// a rendered page for a 404, 503 while the circuit is open, 502 otherwise.
+func upstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *jsonplaceholder.APIError
 
-	resp, err := http.Get(base + r.URL.Path[1:])
+	switch {
+	case errors.Is(err, jsonplaceholder.ErrNotFound):
+		notFound(w, r)
 
-	if err != nil {
+	case errors.Is(err, outbound.ErrCircuitOpen):
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+	case errors.As(err, &apiErr):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+
+	case r.Context().Err() != nil:
+		// our own client went away, nobody will read the answer
+
+	default:
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		return
 	}
+}
 
-	defer resp.Body.Close()
+func routes() *http.ServeMux {
+	mux := http.NewServeMux()
 
-	if resp.StatusCode == http.StatusOK {
-		// body, err := io.ReadAll(resp.Body)
+	mux.HandleFunc("GET /todos", listTodos)
+	mux.HandleFunc("GET /todos/{id}", showTodo)
+	mux.HandleFunc("GET /users/{id}/todos", userTodos)
+	mux.Handle("GET /debug/vars", expvar.Handler()) // cache hits and misses, among others
+	mux.Handle("GET /{$}", http.RedirectHandler("/todos", http.StatusFound))
+	mux.HandleFunc("/", notFound) // Anything else stays here, it's never passed upstream
 
-		// if err != nil {
-		// 	http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		// 	return
-		// }
+	return mux
+}
 
-		item := todo{}
+// transports returns the two ways out to upstream. Both retry with backoff,
+// then go through a breaker that stops hammering the upstream host while
+// it's down; our own client is also answered from the cache if possible.
+// The proxy never is: it passes on each client's credentials, and only
+// its own limits on time and body size should decide how much it reads.
+func transports(responses *cache.Cache, retry outbound.RetryPolicy, breaker outbound.BreakerPolicy) (client, proxy http.RoundTripper) {
+	client = outbound.Chain(http.DefaultTransport,
+		responses.Wrap,
+		outbound.Retry(retry),
+		outbound.CircuitBreaker(breaker),
+	)
+
+	proxy = outbound.Chain(http.DefaultTransport,
+		outbound.Retry(retry),
+		outbound.CircuitBreaker(breaker),
+	)
+
+	return client, proxy
+}
 
-		if err = json.NewDecoder(resp.Body).Decode(&item); err != nil {
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-			return
+func main() {
+	// Point -upstream at the fake server (example_03.3) to work offline
+	upstream := flag.String("upstream", jsonplaceholder.DefaultBaseURL, "JSONPlaceholder base URL")
+	retries := flag.Int("retries", 3, "attempts per idempotent upstream request")
+	failures := flag.Int("breaker-failures", 5, "consecutive upstream failures that open the circuit")
+	dev := flag.Bool("dev", false, "reload templates from ./templates on every request")
+	ttl := flag.Duration("cache-ttl", time.Minute, "how long upstream responses are fresh")
+	stale := flag.Duration("cache-stale", 5*time.Minute, "how long stale responses are served while refreshed")
+	entries := flag.Int("cache-entries", 1000, "maximum number of cached upstream responses")
+	proxy := flag.Bool("proxy", false, "pass every request on to upstream, rendering only the todo pages")
+	timeout := flag.Duration("proxy-timeout", 10*time.Second, "how long an upstream exchange may take in proxy mode")
+	maxRequest := flag.Int64("max-request-bytes", 1<<20, "largest request body passed upstream in proxy mode")
+	maxResponse := flag.Int64("max-response-bytes", 10<<20, "largest upstream response passed back in proxy mode")
+
+	setHeaders := http.Header{}
+	flag.Func("proxy-header", "`Name: value` header added to upstream requests in proxy mode (repeatable)", func(s string) error {
+		name, value, ok := strings.Cut(s, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("want Name: value, got %q", s)
 		}
 
-		tmpl, err := template.New("todo").Parse(form)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-			return
-		}
+		setHeaders.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		return nil
+	})
+
+	flag.Parse()
+
+	var templateFS fs.FS
+
+	if *dev {
+		templateFS = os.DirFS("templates")
+	} else {
+		templateFS, _ = fs.Sub(embedded, "templates")
+	}
+
+	var err error
+
+	if pages, err = newRenderer(templateFS, *dev); err != nil {
+		log.Fatal(err)
+	}
 
-		err = tmpl.Execute(w, item)
+	responses := cache.New(cache.Options{TTL: *ttl, StaleWhileRevalidate: *stale, MaxEntries: *entries})
+	expvar.Publish("cache", expvar.Func(func() any { return responses.Stats() }))
 
+	clientTransport, proxyTransport := transports(responses,
+		outbound.RetryPolicy{MaxAttempts: *retries},
+		outbound.BreakerPolicy{FailureThreshold: *failures},
+	)
+
+	client = jsonplaceholder.NewClient(*upstream, &http.Client{Transport: clientTransport})
+
+	var handler http.Handler = routes()
+
+	if *proxy {
+		u, err := url.Parse(*upstream)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-			return
+			log.Fatal(err)
 		}
 
+		handler, err = newProxy(proxyConfig{
+			upstream:            u,
+			transport:           proxyTransport,
+			timeout:             *timeout,
+			maxRequestBytes:     *maxRequest,
+			maxResponseBytes:    *maxResponse,
+			setHeaders:          setHeaders,
+			dropResponseHeaders: []string{"Set-Cookie", "X-Powered-By", "Report-To", "Nel"},
+			transforms:          todoTransforms(),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
-}
 
-func main() {
-	http.HandleFunc("/", handler)                // Bind the handler to the top-leve route
-	log.Fatal(http.ListenAndServe(":8080", nil)) // Start the server on port 8080
+	log.Fatal(http.ListenAndServe(":8080", handler)) // Start the server on port 8080
 }
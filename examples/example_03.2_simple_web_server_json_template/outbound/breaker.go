@@ -0,0 +1,138 @@
+package outbound
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned (wrapped) instead of calling a host whose
+// circuit is open.
+var ErrCircuitOpen = errors.New("outbound: circuit open")
+
+// BreakerPolicy says when to stop calling a failing host. Zero fields get
+// the defaults noted.
+type BreakerPolicy struct {
+	FailureThreshold int           // consecutive failures that open the circuit (5)
+	OpenTimeout      time.Duration // how long it stays open before a trial request (10s)
+}
+
+// CircuitBreaker keeps one breaker per host. After FailureThreshold
+// consecutive failures (network errors or 5xx responses) requests to the
+// host fail fast with ErrCircuitOpen; once OpenTimeout has passed a single
+// trial request is let through, and its outcome closes or reopens the circuit.
+func CircuitBreaker(p BreakerPolicy) Middleware {
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = 5
+	}
+
+	if p.OpenTimeout <= 0 {
+		p.OpenTimeout = 10 * time.Second
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &breakers{policy: p, next: next, hosts: map[string]*breaker{}, now: time.Now}
+	}
+}
+
+type state int
+
+const (
+	closed state = iota
+	open
+	halfOpen
+)
+
+type breaker struct {
+	state     state
+	failures  int
+	openUntil time.Time
+}
+
+type breakers struct {
+	policy BreakerPolicy
+	next   http.RoundTripper
+	now    func() time.Time // replaced in tests
+
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+func (b *breakers) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	if err := b.allow(host); err != nil {
+		return nil, err
+	}
+
+	resp, err := b.next.RoundTrip(req)
+
+	// a cancelled request says nothing about the host's health
+	if err != nil && req.Context().Err() != nil {
+		b.release(host)
+		return resp, err
+	}
+
+	b.record(host, err == nil && resp.StatusCode < 500)
+
+	return resp, err
+}
+
+func (b *breakers) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br := b.hosts[host]
+
+	if br == nil {
+		br = &breaker{}
+		b.hosts[host] = br
+	}
+
+	switch br.state {
+	case open:
+		if b.now().Before(br.openUntil) {
+			return fmt.Errorf("%w for %s", ErrCircuitOpen, host)
+		}
+
+		br.state = halfOpen // this request is the trial
+		return nil
+
+	case halfOpen:
+		// the trial request is still running
+		return fmt.Errorf("%w for %s", ErrCircuitOpen, host)
+	}
+
+	return nil
+}
+
+func (b *breakers) record(host string, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br := b.hosts[host]
+
+	if ok {
+		br.state, br.failures = closed, 0
+		return
+	}
+
+	br.failures++
+
+	if br.state == halfOpen || br.failures >= b.policy.FailureThreshold {
+		br.state = open
+		br.openUntil = b.now().Add(b.policy.OpenTimeout)
+	}
+}
+
+// release lets another trial through if this one was cancelled.
+func (b *breakers) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if br := b.hosts[host]; br.state == halfOpen {
+		br.state = open
+	}
+}
@@ -0,0 +1,35 @@
+// Package outbound wraps an http.RoundTripper with the resilience every
+// call to an upstream service needs: bounded retries with backoff and a
+// circuit breaker per host.
+//
+//	client := &http.Client{Transport: outbound.Chain(http.DefaultTransport,
+//		outbound.Retry(outbound.RetryPolicy{}),
+//		outbound.CircuitBreaker(outbound.BreakerPolicy{}),
+//	)}
+package outbound
+
+import "net/http"
+
+// Middleware wraps a RoundTripper with some extra behaviour.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc lets an ordinary function act as a RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Chain applies the middlewares so the first one is the outermost; a nil
+// rt means http.DefaultTransport.
+func Chain(rt http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	for i := len(mws) - 1; i >= 0; i-- {
+		rt = mws[i](rt)
+	}
+
+	return rt
+}
@@ -0,0 +1,170 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flaky fails the first n requests with status.
+func flaky(n int32, status int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}))
+
+	return srv, &calls
+}
+
+func noSleep(context.Context, time.Duration) error { return nil }
+
+func TestRetry(t *testing.T) {
+	srv, calls := flaky(2, http.StatusServiceUnavailable)
+	defer srv.Close()
+
+	rt := Retry(RetryPolicy{MaxAttempts: 3})(http.DefaultTransport).(*retrier)
+	rt.sleep = noSleep
+
+	resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("got %d after %d calls, want 200 after 3", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	srv, calls := flaky(10, http.StatusBadGateway)
+	defer srv.Close()
+
+	rt := Retry(RetryPolicy{MaxAttempts: 2})(http.DefaultTransport).(*retrier)
+	rt.sleep = noSleep
+
+	resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway || calls.Load() != 2 {
+		t.Errorf("got %d after %d calls, want 502 after 2", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestNoRetryForPost(t *testing.T) {
+	srv, calls := flaky(1, http.StatusServiceUnavailable)
+	defer srv.Close()
+
+	rt := Retry(RetryPolicy{})(http.DefaultTransport).(*retrier)
+	rt.sleep = noSleep
+
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if calls.Load() != 1 {
+		t.Errorf("POST sent %d times", calls.Load())
+	}
+
+	// unless the client says it's safe
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("hi"))
+	req.Header.Set("Idempotency-Key", "abc")
+	calls.Store(0)
+
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("got %d after %d calls", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	rt := &retrier{policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}}
+
+	for attempt := 1; attempt < 70; attempt++ {
+		if d := rt.backoff(attempt, nil); d < 0 || d > time.Second {
+			t.Fatalf("attempt %d: backoff %s out of range", attempt, d)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	srv, calls := flaky(3, http.StatusInternalServerError)
+	defer srv.Close()
+
+	now := time.Now()
+
+	br := CircuitBreaker(BreakerPolicy{FailureThreshold: 3, OpenTimeout: time.Minute})(http.DefaultTransport).(*breakers)
+	br.now = func() time.Time { return now }
+
+	client := &http.Client{Transport: br}
+
+	get := func() (int, error) {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if status, err := get(); status != 500 || err != nil {
+			t.Fatalf("call %d: got %d, %v", i, status, err)
+		}
+	}
+
+	// the circuit is open: fail fast without calling the host
+	if _, err := get(); !errors.Is(err, ErrCircuitOpen) || calls.Load() != 3 {
+		t.Fatalf("got %v after %d calls, want an open circuit", err, calls.Load())
+	}
+
+	// after the timeout a trial request goes through and closes it again
+	now = now.Add(time.Minute)
+
+	if status, err := get(); status != 200 || err != nil {
+		t.Fatalf("trial got %d, %v", status, err)
+	}
+
+	if status, err := get(); status != 200 || err != nil {
+		t.Fatalf("got %d, %v after closing", status, err)
+	}
+}
+
+func TestRetryStopsAtOpenCircuit(t *testing.T) {
+	srv, calls := flaky(100, http.StatusServiceUnavailable)
+	defer srv.Close()
+
+	rt := Chain(http.DefaultTransport,
+		Retry(RetryPolicy{MaxAttempts: 10, BaseDelay: time.Microsecond}),
+		CircuitBreaker(BreakerPolicy{FailureThreshold: 2}),
+	)
+
+	_, err := (&http.Client{Transport: rt}).Get(srv.URL)
+
+	if !errors.Is(err, ErrCircuitOpen) || calls.Load() != 2 {
+		t.Errorf("got %v after %d calls", err, calls.Load())
+	}
+}
@@ -0,0 +1,136 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy bounds the retries. Zero fields get the defaults noted.
+type RetryPolicy struct {
+	MaxAttempts int           // attempts in total, including the first (3)
+	BaseDelay   time.Duration // backoff before the first retry (100ms)
+	MaxDelay    time.Duration // cap on any single backoff (2s)
+}
+
+// Retry retries idempotent requests that failed with a network error or a
+// 429 / 502 / 503 / 504 response. The backoff doubles with each attempt and
+// is fully jittered, so many clients failing together don't retry together.
+// A Retry-After header from the server is honoured, up to MaxDelay.
+func Retry(p RetryPolicy) Middleware {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 100 * time.Millisecond
+	}
+
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 2 * time.Second
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &retrier{policy: p, next: next, sleep: sleep}
+	}
+}
+
+type retrier struct {
+	policy RetryPolicy
+	next   http.RoundTripper
+	sleep  func(context.Context, time.Duration) error // replaced in tests
+}
+
+func (rt *retrier) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a body can only be sent again if we know how to rewind it
+	if !idempotent(req) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return rt.next.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := rt.next.RoundTrip(req)
+
+		if attempt == rt.policy.MaxAttempts || !retryable(req, resp, err) {
+			return resp, err
+		}
+
+		delay := rt.backoff(attempt, resp)
+
+		if resp != nil {
+			// drain so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		if err := rt.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (rt *retrier) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, rt.policy.MaxDelay)
+		}
+	}
+
+	d := rt.policy.BaseDelay << (attempt - 1)
+
+	if d <= 0 || d > rt.policy.MaxDelay { // <= 0 on overflow
+		d = rt.policy.MaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+
+	// the convention for making a POST safe to repeat
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		// nobody is waiting for the answer any more, and an open
+		// circuit won't close by hammering it
+		return req.Context().Err() == nil && !errors.Is(err, ErrCircuitOpen)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
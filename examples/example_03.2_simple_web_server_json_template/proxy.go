@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"jsonplaceholder"
+
+	"simple_web_server_json_template/outbound"
+)
+
+// errResponseTooLarge is what reading an upstream body beyond
+// proxyConfig.maxResponseBytes returns.
+var errResponseTooLarge = errors.New("upstream response too large")
+
+// transformer turns the JSON body of a successful upstream response into
+// the data for the page called name.
+type transformer func(resp *http.Response, body []byte) (name string, data any, err error)
+
+// proxyConfig sets up the proxy mode: instead of our own routes, every
+// request is passed on to upstream as it is, and only the routes that have a
+// transformer get rendered through the templates.
+type proxyConfig struct {
+	upstream  *url.URL
+	transport http.RoundTripper // nil for http.DefaultTransport
+	timeout   time.Duration     // for the whole upstream exchange (0: none)
+
+	maxRequestBytes  int64 // 0: no limit
+	maxResponseBytes int64 // 0: no limit
+
+	setHeaders          http.Header // added to every upstream request
+	dropResponseHeaders []string    // never passed back to our clients
+
+	transforms map[string]transformer // by ServeMux pattern, like "GET /todos/{id}"
+}
+
+// proxyRoute is what the handler found out about a request before
+// passing it to the ReverseProxy.
+type proxyRoute struct {
+	transform transformer
+	accept    string // what our client asked for
+}
+
+type proxyRouteKey struct{}
+
+// todoTransforms renders the todo pages in proxy mode, the others stay JSON.
+func todoTransforms() map[string]transformer {
+	return map[string]transformer{
+		"GET /todos/{id}": func(_ *http.Response, body []byte) (string, any, error) {
+			var todo jsonplaceholder.Todo
+			err := json.Unmarshal(body, &todo)
+
+			return "todo", todo, err
+		},
+
+		"GET /todos": func(resp *http.Response, body []byte) (string, any, error) {
+			page := todoPage{Page: 1}
+
+			if err := json.Unmarshal(body, &page.Todos); err != nil {
+				return "", nil, err
+			}
+
+			// these are upstream's parameters, the client talks to it directly
+			q := resp.Request.URL.Query()
+
+			if n, err := strconv.Atoi(q.Get("_page")); err == nil && n > 0 {
+				page.Page = n
+			}
+
+			page.Size = len(page.Todos)
+
+			if n, err := strconv.Atoi(q.Get("_limit")); err == nil && n > 0 {
+				page.Size = n
+			}
+
+			page.Total = len(page.Todos)
+
+			if n, err := strconv.Atoi(resp.Header.Get("X-Total-Count")); err == nil {
+				page.Total = n
+			}
+
+			if page.Size > 0 {
+				page.Pages = (page.Total + page.Size - 1) / page.Size
+			}
+
+			return "todos", page, nil
+		},
+	}
+}
+
+// newProxy returns a handler sending everything to cfg.upstream.
+func newProxy(cfg proxyConfig) (http.Handler, error) {
+	if cfg.upstream == nil || cfg.upstream.Scheme == "" || cfg.upstream.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL %v", cfg.upstream)
+	}
+
+	if cfg.transport == nil {
+		cfg.transport = http.DefaultTransport
+	}
+
+	// a mux of our own only tells us which transform applies, if any
+	routes := http.NewServeMux()
+
+	for pattern := range cfg.transforms {
+		routes.Handle(pattern, http.NotFoundHandler())
+	}
+
+	rp := &httputil.ReverseProxy{
+		Transport: cfg.transport,
+
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(cfg.upstream)
+			pr.SetXForwarded()
+
+			pr.Out.Header.Del("Cookie") // they're ours, not upstream's
+
+			for name, values := range cfg.setHeaders {
+				pr.Out.Header[name] = values
+			}
+
+			if route := routeFrom(pr.In.Context()); route.transform != nil {
+				// we need plain JSON to render it
+				pr.Out.Header.Set("Accept", mimeJSON)
+				pr.Out.Header.Del("Accept-Encoding")
+			}
+		},
+
+		ModifyResponse: func(resp *http.Response) error {
+			for _, name := range cfg.dropResponseHeaders {
+				resp.Header.Del(name)
+			}
+
+			if cfg.maxResponseBytes > 0 {
+				if resp.ContentLength > cfg.maxResponseBytes {
+					return errResponseTooLarge
+				}
+
+				resp.Body = &limitedBody{resp.Body, cfg.maxResponseBytes}
+			}
+
+			return transform(resp, routeFrom(resp.Request.Context()))
+		},
+
+		ErrorHandler: proxyError,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.maxRequestBytes > 0 {
+			if r.ContentLength > cfg.maxRequestBytes {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.maxRequestBytes)
+		}
+
+		ctx := r.Context()
+
+		if cfg.timeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+			defer cancel()
+		}
+
+		route := proxyRoute{accept: r.Header.Get("Accept")}
+
+		if _, pattern := routes.Handler(r); pattern != "" {
+			route.transform = cfg.transforms[pattern]
+		}
+
+		rp.ServeHTTP(w, r.WithContext(context.WithValue(ctx, proxyRouteKey{}, route)))
+	}), nil
+}
+
+func routeFrom(ctx context.Context) proxyRoute {
+	route, _ := ctx.Value(proxyRouteKey{}).(proxyRoute)
+	return route
+}
+
+// transform renders a JSON response as the page the client asked for, if
+// its route has a transformer and the client doesn't want JSON anyway.
+func transform(resp *http.Response, route proxyRoute) error {
+	if route.transform == nil {
+		return nil
+	}
+
+	resp.Header.Add("Vary", "Accept")
+
+	if negotiate(route.accept, mimeHTML, mimeJSON, mimeText) == mimeJSON {
+		return nil
+	}
+
+	var (
+		name string
+		data any
+	)
+
+	switch ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); {
+	case resp.StatusCode == http.StatusNotFound:
+		name, data = "notfound", notFoundPage{"not found", resp.Request.URL.Path}
+
+	case resp.StatusCode != http.StatusOK || ct != mimeJSON:
+		return nil // errors and anything else pass through untouched
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		if name, data, err = route.transform(resp, body); err != nil {
+			return fmt.Errorf("transforming upstream response: %w", err)
+		}
+	}
+
+	contentType, page, err := pages.encode(route.accept, name, data)
+	if err != nil {
+		return err
+	}
+
+	// it's a different body now, so the upstream validators don't apply
+	resp.Header.Del("ETag")
+	resp.Header.Del("Last-Modified")
+	resp.Header.Del("Content-Encoding")
+
+	resp.Header.Set("Content-Type", contentType)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(page)))
+	resp.ContentLength = int64(len(page))
+	resp.Body = io.NopCloser(bytes.NewReader(page))
+
+	return nil
+}
+
+// proxyError answers the requests upstream didn't: 413 when our client sent
+// too much, 504 on a timeout, 503 while the circuit is open, 502 otherwise.
+func proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+
+	case errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, "upstream timed out", http.StatusGatewayTimeout)
+
+	case errors.Is(err, context.Canceled):
+		// our own client went away, nobody will read the answer
+
+	case errors.Is(err, outbound.ErrCircuitOpen):
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+	default:
+		log.Printf("proxy %s %s: %v", r.Method, r.URL, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+// limitedBody fails with errResponseTooLarge rather than quietly stopping
+// the way io.LimitReader does, so a cut-off body isn't mistaken for a whole one.
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		// one byte tells a body of exactly the limit from a longer one
+		n, err := b.ReadCloser.Read(make([]byte, 1))
+		if n > 0 {
+			return 0, errResponseTooLarge
+		}
+
+		return 0, err
+	}
+
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+
+	return n, err
+}
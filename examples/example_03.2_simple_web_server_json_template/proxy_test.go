@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"jsonplaceholder"
+	"jsonplaceholder/cache"
+	"jsonplaceholder/fake"
+
+	"simple_web_server_json_template/outbound"
+)
+
+// proxySetup puts a proxy with the todo transforms in front of upstream;
+// cfg only needs the limits the test is about.
+func proxySetup(t *testing.T, upstream http.Handler, cfg proxyConfig) http.Handler {
+	t.Helper()
+
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+
+	fsys, _ := fs.Sub(embedded, "templates")
+
+	var err error
+
+	if pages, err = newRenderer(fsys, false); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.upstream, _ = url.Parse(srv.URL)
+	cfg.transforms = todoTransforms()
+
+	h, err := newProxy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+func TestProxyTransform(t *testing.T) {
+	h := proxySetup(t, fake.New(fake.DefaultFixtures()), proxyConfig{})
+
+	rec := get(h, "/todos/4", "application/json")
+
+	var todo jsonplaceholder.Todo
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &todo); err != nil || todo.ID != 4 {
+		t.Errorf("json: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = get(h, "/todos/4", "text/html")
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") ||
+		!strings.Contains(rec.Body.String(), "<h1>Todo #4</h1>") {
+		t.Errorf("html: %s %s", ct, rec.Body)
+	}
+
+	if rec.Header().Get("Vary") != "Accept" {
+		t.Errorf("missing Vary, got %v", rec.Header())
+	}
+
+	rec = get(h, "/todos?userId=2&_page=2&_limit=5", "text/plain")
+
+	if !strings.Contains(rec.Body.String(), "20 todos, page 2 of 4") {
+		t.Errorf("list: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = get(h, "/todos/999", "text/html")
+
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "/todos/999") {
+		t.Errorf("not found: %d %s", rec.Code, rec.Body)
+	}
+
+	// routes without a transform pass through as they are
+	rec = get(h, "/posts/1", "text/html")
+
+	if ct := rec.Header().Get("Content-Type"); rec.Code != http.StatusOK || !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("posts: %d %s", rec.Code, ct)
+	}
+}
+
+func TestProxyHeaders(t *testing.T) {
+	var got http.Header
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+
+		w.Header().Set("Set-Cookie", "session=upstream")
+		w.Header().Set("X-Upstream", "yes")
+		w.Write([]byte("{}"))
+	})
+
+	h := proxySetup(t, upstream, proxyConfig{
+		setHeaders:          http.Header{"X-Api-Key": {"secret"}},
+		dropResponseHeaders: []string{"Set-Cookie"},
+	})
+
+	req := httptest.NewRequest("GET", "/posts", nil)
+	req.Header.Set("Cookie", "session=ours")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got.Get("X-Api-Key") != "secret" || got.Get("Cookie") != "" || got.Get("X-Forwarded-For") == "" {
+		t.Errorf("upstream got %v", got)
+	}
+
+	if rec.Header().Get("Set-Cookie") != "" || rec.Header().Get("X-Upstream") != "yes" {
+		t.Errorf("client got %v", rec.Header())
+	}
+}
+
+func TestProxyLimits(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		}
+	})
+
+	h := proxySetup(t, upstream, proxyConfig{
+		timeout:          50 * time.Millisecond,
+		maxRequestBytes:  10,
+		maxResponseBytes: 50,
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/posts", strings.NewReader(strings.Repeat("x", 20))))
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large request: got %d", rec.Code)
+	}
+
+	if rec := get(h, "/large", ""); rec.Code != http.StatusBadGateway {
+		t.Errorf("large response: got %d", rec.Code)
+	}
+
+	if rec := get(h, "/slow", ""); rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("slow response: got %d", rec.Code)
+	}
+}
+
+// The proxy's limits must hold on the transport main gives it: it reads no
+// more of a body than allowed, and upstream hears of a timeout, whatever
+// the cache in front of our own client would do with the same responses.
+func TestProxyTransport(t *testing.T) {
+	var written atomic.Int64
+	cancelled := make(chan struct{})
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+				close(cancelled)
+			}
+
+		case "/huge":
+			// until the proxy hangs up, or 64MB
+			chunk := []byte(strings.Repeat("x", 64<<10))
+
+			for range 1024 {
+				n, err := w.Write(chunk)
+				written.Add(int64(n))
+
+				if err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	_, transport := transports(cache.New(cache.Options{}),
+		outbound.RetryPolicy{MaxAttempts: 1},
+		outbound.BreakerPolicy{},
+	)
+
+	h := proxySetup(t, upstream, proxyConfig{
+		transport:        transport,
+		timeout:          50 * time.Millisecond,
+		maxResponseBytes: 50,
+	})
+
+	// with no Content-Length, the headers are gone before the limit is hit,
+	// so the body is all there is to cut
+	if rec := get(h, "/huge", ""); rec.Body.Len() > 50 {
+		t.Errorf("huge response: got %d bytes", rec.Body.Len())
+	}
+
+	// what's left in the socket buffers on the way is all upstream gets out
+	if n := written.Load(); n > 16<<20 {
+		t.Errorf("upstream wrote %d bytes", n)
+	}
+
+	if rec := get(h, "/slow", ""); rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("slow response: got %d", rec.Code)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("upstream call outlived the proxy timeout")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+)
+
+// The templates are compiled into the binary. Every page NAME.html defines
+// "title" and "content" blocks that fill in layout.html; NAME.txt is the
+// plain-text version of the same page.
+//
+//go:embed templates
+var embedded embed.FS
+
+const (
+	mimeHTML = "text/html"
+	mimeJSON = "application/json"
+	mimeText = "text/plain"
+)
+
+type templates struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+// renderer writes a page as HTML, JSON or plain text, whichever the client
+// prefers. Templates are parsed once, unless dev is set: then they are
+// parsed from disk on every request, so edits show up on reload.
+type renderer struct {
+	fsys fs.FS
+	dev  bool
+	tmpl *templates
+}
+
+func newRenderer(fsys fs.FS, dev bool) (*renderer, error) {
+	rn := &renderer{fsys: fsys, dev: dev}
+
+	// parse even in dev mode, so mistakes show up at startup
+	t, err := parseTemplates(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	rn.tmpl = t
+	return rn, nil
+}
+
+func parseTemplates(fsys fs.FS) (*templates, error) {
+	t := &templates{
+		html: map[string]*htmltemplate.Template{},
+		text: map[string]*texttemplate.Template{},
+	}
+
+	layout, err := htmltemplate.ParseFS(fsys, "layout.html")
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := fs.Glob(fsys, "*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, page := range pages {
+		if page == "layout.html" {
+			continue
+		}
+
+		// each page gets its own copy of the layout, or the
+		// "content" blocks of the pages would overwrite each other
+		tmpl, err := htmltemplate.Must(layout.Clone()).ParseFS(fsys, page)
+		if err != nil {
+			return nil, err
+		}
+
+		t.html[strings.TrimSuffix(page, ".html")] = tmpl
+	}
+
+	texts, err := fs.Glob(fsys, "*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, page := range texts {
+		tmpl, err := texttemplate.ParseFS(fsys, page)
+		if err != nil {
+			return nil, err
+		}
+
+		t.text[strings.TrimSuffix(page, ".txt")] = tmpl
+	}
+
+	return t, nil
+}
+
+// render writes data with the given status, as the page called name in the
+// format the Accept header asks for. JSON is just the data itself.
+func (rn *renderer) render(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	contentType, body, err := rn.encode(r.Header.Get("Accept"), name, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// encode renders the page into memory, so a template error doesn't
+// leave a half-written page behind, and returns it with its content type.
+func (rn *renderer) encode(accept, name string, data any) (string, []byte, error) {
+	t := rn.tmpl
+
+	if rn.dev {
+		var err error
+
+		if t, err = parseTemplates(rn.fsys); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var b bytes.Buffer
+
+	switch negotiate(accept, mimeHTML, mimeJSON, mimeText) {
+	case mimeJSON:
+		if err := json.NewEncoder(&b).Encode(data); err != nil {
+			return "", nil, err
+		}
+
+		return "application/json; charset=utf-8", b.Bytes(), nil
+
+	case mimeText:
+		tmpl, ok := t.text[name]
+		if !ok {
+			return "", nil, fmt.Errorf("no text template %q", name)
+		}
+
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", nil, err
+		}
+
+		return "text/plain; charset=utf-8", b.Bytes(), nil
+
+	default:
+		tmpl, ok := t.html[name]
+		if !ok {
+			return "", nil, fmt.Errorf("no html template %q", name)
+		}
+
+		if err := tmpl.ExecuteTemplate(&b, "layout.html", data); err != nil {
+			return "", nil, err
+		}
+
+		return "text/html; charset=utf-8", b.Bytes(), nil
+	}
+}
+
+// negotiate picks the offer the Accept header likes best, honouring q
+// values and wildcards such as text/*. Ties go to the earlier offer and
+// so does an empty or unsatisfiable header.
+func negotiate(accept string, offers ...string) string {
+	type pref struct {
+		mime string
+		q    float64
+	}
+
+	var prefs []pref
+
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mime := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		if mime == "" {
+			continue
+		}
+
+		q := 1.0
+
+		for _, param := range fields[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+
+			if strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		prefs = append(prefs, pref{mime, q})
+	}
+
+	// exact types first, then text/*, then */* (at equal q)
+	sort.SliceStable(prefs, func(i, j int) bool {
+		return strings.Count(prefs[i].mime, "*") < strings.Count(prefs[j].mime, "*")
+	})
+
+	best, bestQ := offers[0], 0.0
+
+	for _, offer := range offers {
+		for _, p := range prefs {
+			if ok, _ := path.Match(p.mime, offer); !ok {
+				continue
+			}
+
+			if p.q > bestQ {
+				best, bestQ = offer, p.q
+			}
+
+			break // the most specific match decides
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jsonplaceholder"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{mimeHTML, mimeJSON, mimeText}
+
+	tests := map[string]string{
+		"":                                   mimeHTML,
+		"*/*":                                mimeHTML,
+		"application/json":                   mimeJSON,
+		"text/plain":                         mimeText,
+		"text/*":                             mimeHTML,
+		"text/*, text/html;q=0":              mimeText,
+		"text/html;q=0.5, application/json":  mimeJSON,
+		"application/json;q=0.2, */*;q=0.1":  mimeJSON,
+		"image/png":                          mimeHTML,
+		"TEXT/PLAIN; charset=utf-8; q=0.9":   mimeText,
+		"text/html, application/xhtml+xml":   mimeHTML,
+		"application/xml, text/plain;q=0.8,": mimeText,
+	}
+
+	for accept, want := range tests {
+		if got := negotiate(accept, offers...); got != want {
+			t.Errorf("%q: got %s, want %s", accept, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	fsys, _ := fs.Sub(embedded, "templates")
+
+	rn, err := newRenderer(fsys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := jsonplaceholder.Todo{UserID: 1, ID: 4, Title: "et porro <b>tempora</b>", Completed: true}
+
+	tests := []struct{ accept, contentType, body string }{
+		{"text/html", "text/html", "<title>Todo #4</title>"},
+		{"text/html", "text/html", "et porro &lt;b&gt;tempora&lt;/b&gt;"},
+		{"application/json", "application/json", `"id":4,`},
+		{"text/plain", "text/plain", "[x] 4 - et porro <b>tempora</b> (user 1)"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/todos/4", nil)
+		req.Header.Set("Accept", tt.accept)
+
+		rec := httptest.NewRecorder()
+		rn.render(rec, req, http.StatusOK, "todo", item)
+
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+			t.Errorf("%s: got content type %s", tt.accept, ct)
+		}
+
+		if !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s: %q not found in\n%s", tt.accept, tt.body, rec.Body.String())
+		}
+	}
+}
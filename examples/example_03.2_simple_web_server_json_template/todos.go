@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"jsonplaceholder"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// the fields a todo list can be sorted by
+var sortFields = map[string]bool{"id": true, "userId": true, "title": true, "completed": true}
+
+type todoPage struct {
+	Todos []jsonplaceholder.Todo `json:"todos"`
+	Page  int                    `json:"page"`
+	Size  int                    `json:"size"`
+	Total int                    `json:"total"`
+	Pages int                    `json:"pages"`
+	Prev  string                 `json:"prev,omitempty"`
+	Next  string                 `json:"next,omitempty"`
+}
+
+type summary struct {
+	Total     int     `json:"total"`
+	Completed int     `json:"completed"`
+	Pending   int     `json:"pending"`
+	Percent   float64 `json:"percent"`
+}
+
+type userTodosPage struct {
+	User    jsonplaceholder.User   `json:"user"`
+	Todos   []jsonplaceholder.Todo `json:"todos"`
+	Summary summary                `json:"summary"`
+}
+
+// listTodos serves /todos?userId=1&completed=false&page=2&size=10&sort=title&order=desc
+func listTodos(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	opts, err := listOptions(q)
+	if err != nil {
+		pages.render(w, r, http.StatusBadRequest, "badrequest", badRequestPage{err.Error()})
+		return
+	}
+
+	result, err := client.Todos.List(r.Context(), opts)
+	if err != nil {
+		upstreamError(w, r, err)
+		return
+	}
+
+	page := todoPage{
+		Todos: result.Items,
+		Page:  opts.Page,
+		Size:  opts.Limit,
+		Total: result.Total,
+		Pages: (result.Total + opts.Limit - 1) / opts.Limit,
+	}
+
+	if page.Todos == nil {
+		page.Todos = []jsonplaceholder.Todo{} // [] rather than null in JSON
+	}
+
+	if page.Page > 1 {
+		page.Prev = pageLink(r.URL, page.Page-1)
+	}
+
+	if page.Page < page.Pages {
+		page.Next = pageLink(r.URL, page.Page+1)
+	}
+
+	pages.render(w, r, http.StatusOK, "todos", page)
+}
+
+// showTodo serves /todos/{id}
+func showTodo(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	// The client sends the request with our request's context (so it's
+	// cancelled if our client goes away), checks the status and decodes the body
+	item, err := client.Todos.Get(r.Context(), id)
+
+	if err != nil {
+		upstreamError(w, r, err)
+		return
+	}
+
+	// HTML, JSON or plain text, depending on the Accept header
+	pages.render(w, r, http.StatusOK, "todo", item)
+}
+
+// userTodos serves /users/{id}/todos with a summary of how much is done
+func userTodos(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := client.Users.Get(r.Context(), id)
+	if err != nil {
+		upstreamError(w, r, err)
+		return
+	}
+
+	result, err := client.Todos.List(r.Context(), (&jsonplaceholder.ListOptions{Sort: "id"}).Where("userId", id))
+	if err != nil {
+		upstreamError(w, r, err)
+		return
+	}
+
+	page := userTodosPage{User: user, Todos: result.Items}
+
+	if page.Todos == nil {
+		page.Todos = []jsonplaceholder.Todo{}
+	}
+
+	for _, t := range page.Todos {
+		if t.Completed {
+			page.Summary.Completed++
+		}
+	}
+
+	page.Summary.Total = len(page.Todos)
+	page.Summary.Pending = page.Summary.Total - page.Summary.Completed
+
+	if page.Summary.Total > 0 {
+		page.Summary.Percent = 100 * float64(page.Summary.Completed) / float64(page.Summary.Total)
+	}
+
+	pages.render(w, r, http.StatusOK, "user_todos", page)
+}
+
+// notFound answers every path we don't have a route for, without
+// asking upstream: we only ever send requests we built ourselves.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	pages.render(w, r, http.StatusNotFound, "notfound", notFoundPage{"not found", r.URL.Path})
+}
+
+// pathID validates the {id} wildcard; anything but a positive number is a 404.
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+
+	if err != nil || id < 1 {
+		notFound(w, r)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// listOptions checks the query parameters of a list page and translates
+// them into the upstream's filter, sort and pagination parameters.
+func listOptions(q url.Values) (*jsonplaceholder.ListOptions, error) {
+	opts := &jsonplaceholder.ListOptions{Page: 1, Limit: defaultPageSize, Sort: "id", Order: "asc"}
+
+	if v := q.Get("userId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("invalid userId %q", v)
+		}
+
+		opts.Where("userId", id)
+	}
+
+	if v := q.Get("completed"); v != "" {
+		done, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid completed %q, want true or false", v)
+		}
+
+		opts.Where("completed", done)
+	}
+
+	if v := q.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid page %q", v)
+		}
+
+		opts.Page = n
+	}
+
+	if v := q.Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return nil, fmt.Errorf("invalid size %q, want 1 to %d", v, maxPageSize)
+		}
+
+		opts.Limit = n
+	}
+
+	if v := q.Get("sort"); v != "" {
+		if !sortFields[v] {
+			return nil, fmt.Errorf("can't sort by %q", v)
+		}
+
+		opts.Sort = v
+	}
+
+	if v := q.Get("order"); v != "" {
+		if v != "asc" && v != "desc" {
+			return nil, fmt.Errorf("invalid order %q, want asc or desc", v)
+		}
+
+		opts.Order = v
+	}
+
+	return opts, nil
+}
+
+// pageLink is the current URL pointing at another page.
+func pageLink(u *url.URL, page int) string {
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+
+	return u.Path + "?" + q.Encode()
+}
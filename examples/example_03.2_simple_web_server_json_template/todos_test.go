@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"jsonplaceholder"
+	"jsonplaceholder/fake"
+)
+
+// setup points the server at a fake upstream and counts the calls it gets.
+func setup(t *testing.T) (http.Handler, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+
+	f := fake.New(fake.DefaultFixtures())
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		f.ServeHTTP(w, r)
+	}))
+	t.Cleanup(upstream.Close)
+
+	client = jsonplaceholder.NewClient(upstream.URL, upstream.Client())
+
+	fsys, _ := fs.Sub(embedded, "templates")
+
+	var err error
+
+	if pages, err = newRenderer(fsys, false); err != nil {
+		t.Fatal(err)
+	}
+
+	return routes(), &calls
+}
+
+func get(h http.Handler, target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("Accept", accept)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestListTodos(t *testing.T) {
+	h, _ := setup(t)
+
+	rec := get(h, "/todos?userId=2&page=2&size=5&sort=id&order=desc", "application/json")
+
+	var page todoPage
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+	}
+
+	if page.Total != 20 || page.Pages != 4 || len(page.Todos) != 5 || page.Todos[0].ID != 35 {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	if page.Prev == "" || page.Next == "" || !strings.Contains(page.Next, "page=3") || !strings.Contains(page.Next, "userId=2") {
+		t.Errorf("bad links %q %q", page.Prev, page.Next)
+	}
+
+	rec = get(h, "/todos?completed=true&size=100", "application/json")
+	json.Unmarshal(rec.Body.Bytes(), &page)
+
+	for _, todo := range page.Todos {
+		if !todo.Completed {
+			t.Errorf("todo %d is not completed", todo.ID)
+		}
+	}
+}
+
+func TestBadParams(t *testing.T) {
+	h, calls := setup(t)
+
+	for _, target := range []string{
+		"/todos?userId=x",
+		"/todos?completed=maybe",
+		"/todos?page=0",
+		"/todos?size=1000",
+		"/todos?sort=password",
+		"/todos?order=up",
+	} {
+		if rec := get(h, target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d", target, rec.Code)
+		}
+	}
+
+	// rendered like the other error pages, in the format asked for
+	for accept, want := range map[string]string{
+		"text/html":        "<h1>Bad request</h1>",
+		"application/json": `{"error":"invalid order \"up\", want asc or desc"}`,
+		"text/plain":       `bad request: invalid order "up", want asc or desc`,
+	} {
+		if rec := get(h, "/todos?order=up", accept); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: got %d %q", accept, rec.Code, rec.Body)
+		}
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("%d upstream calls for invalid requests", calls.Load())
+	}
+}
+
+func TestNoPassthrough(t *testing.T) {
+	h, calls := setup(t)
+
+	for _, target := range []string{"/posts/1", "/todos/-1", "/todos/1x", "/users/1/posts", "/todos/1/../../users"} {
+		// the mux redirects unclean paths to their clean form
+		if rec := get(h, target, ""); rec.Code != http.StatusNotFound && rec.Code/100 != 3 {
+			t.Errorf("%s: got %d", target, rec.Code)
+		}
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("%d upstream calls for unknown routes", calls.Load())
+	}
+
+	if rec := get(h, "/todos/999", "text/plain"); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "/todos/999") {
+		t.Errorf("missing todo: got %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestUserTodos(t *testing.T) {
+	h, _ := setup(t)
+
+	rec := get(h, "/users/3/todos", "application/json")
+
+	var page userTodosPage
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+	}
+
+	s := page.Summary
+
+	if page.User.ID != 3 || s.Total != 20 || s.Completed+s.Pending != 20 || len(page.Todos) != 20 {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	if rec := get(h, "/users/3/todos", "text/html"); !strings.Contains(rec.Body.String(), "<h1>Todos of "+page.User.Name) {
+		t.Errorf("unexpected html\n%s", rec.Body)
+	}
+}
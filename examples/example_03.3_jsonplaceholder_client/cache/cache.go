@@ -0,0 +1,383 @@
+// Package cache keeps upstream GET responses in memory, so servers built on
+// JSONPlaceholder don't call it for every request they get. It plugs in as
+// an http.RoundTripper:
+//
+//	responses := cache.New(cache.Options{TTL: time.Minute, StaleWhileRevalidate: time.Minute})
+//	client := &http.Client{Transport: responses.Wrap(http.DefaultTransport)}
+//
+// The cache is an LRU bounded by entries and bytes. Stale entries are still
+// served for a while as the cache refreshes them in the background, and
+// concurrent misses for the same URL share one upstream request.
+//
+// Only what any client may see is kept: requests with credentials, and
+// responses that are private, vary on more than Accept or are too big,
+// pass through.
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options bound the cache. Zero fields get the defaults noted.
+type Options struct {
+	TTL                  time.Duration // how long a response is fresh (1m)
+	StaleWhileRevalidate time.Duration // how long after that it's served while being refreshed (0: never)
+	MaxEntries           int           // number of responses kept (1000)
+	MaxBytes             int64         // total size of the bodies kept (32MB)
+	MaxBodyBytes         int64         // largest body kept, bigger ones pass through (1MB, at most MaxBytes)
+}
+
+// Stats counts what the cache did since it was created.
+type Stats struct {
+	Hits          uint64 `json:"hits"`          // fresh responses served from memory
+	StaleHits     uint64 `json:"staleHits"`     // stale responses served while refreshing
+	Misses        uint64 `json:"misses"`        // requests that went upstream
+	Shared        uint64 `json:"shared"`        // misses that waited for another request's upstream call
+	Revalidations uint64 `json:"revalidations"` // background refreshes
+	Evictions     uint64 `json:"evictions"`     // entries dropped to stay within the limits
+	Uncached      uint64 `json:"uncached"`      // requests with credentials, or for bodies too big to keep
+	Entries       int    `json:"entries"`
+	Bytes         int64  `json:"bytes"`
+}
+
+// Cache is safe for concurrent use; share one between all the transports
+// that should see the same responses.
+type Cache struct {
+	opts Options
+	now  func() time.Time // replaced in tests
+
+	mu      sync.Mutex
+	lru     *list.List // of *entry, most recently used first
+	items   map[string]*list.Element
+	flights map[string]*flight
+	stats   Stats
+}
+
+type entry struct {
+	key          string
+	status       int
+	header       http.Header
+	body         []byte
+	stored       time.Time
+	revalidating bool
+
+	// tooLarge marks a URL whose body is bigger than MaxBodyBytes: until
+	// the entry expires, requests for it go straight to upstream
+	tooLarge bool
+}
+
+// flight is an upstream request other callers can wait for.
+type flight struct {
+	done  chan struct{}
+	entry *entry
+	err   error
+}
+
+// New returns an empty cache.
+func New(opts Options) *Cache {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 1000
+	}
+
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 32 << 20
+	}
+
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+
+	opts.MaxBodyBytes = min(opts.MaxBodyBytes, opts.MaxBytes)
+
+	return &Cache{
+		opts:    opts,
+		now:     time.Now,
+		lru:     list.New(),
+		items:   map[string]*list.Element{},
+		flights: map[string]*flight{},
+	}
+}
+
+// Stats returns a snapshot of the counters.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Entries = c.lru.Len()
+
+	return s
+}
+
+// Purge drops every entry.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.items = map[string]*list.Element{}
+	c.stats.Bytes = 0
+}
+
+// Wrap returns a RoundTripper answering GET requests from the cache and
+// sending the rest, and the misses, to next. Responses carry an X-Cache
+// header: HIT, STALE or MISS.
+func (c *Cache) Wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripper{c: c, next: next}
+}
+
+type roundTripper struct {
+	c    *Cache
+	next http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return rt.next.RoundTrip(req)
+	}
+
+	c := rt.c
+
+	// what one client gets with its credentials isn't for the others
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		return c.bypass(req, rt.next)
+	}
+
+	// the same URL can come back in different formats
+	key := req.URL.String() + " " + req.Header.Get("Accept")
+
+	now := c.now()
+
+	c.mu.Lock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		age := now.Sub(e.stored)
+
+		switch {
+		case e.tooLarge && age < c.opts.TTL:
+			c.mu.Unlock()
+			return c.bypass(req, rt.next)
+
+		case e.tooLarge:
+			// expired: ask again, the body may be smaller now
+
+		case age < c.opts.TTL:
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			c.mu.Unlock()
+
+			return e.response(req, "HIT", age), nil
+
+		case age < c.opts.TTL+c.opts.StaleWhileRevalidate:
+			c.lru.MoveToFront(el)
+			c.stats.StaleHits++
+
+			if !e.revalidating {
+				e.revalidating = true
+				c.stats.Revalidations++
+				go c.revalidate(key, req, rt.next, e)
+			}
+
+			c.mu.Unlock()
+
+			return e.response(req, "STALE", age), nil
+		}
+	}
+
+	f, shared := c.join(key, req, rt.next)
+
+	if shared {
+		c.stats.Shared++
+	} else {
+		c.stats.Misses++
+	}
+
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	// the flight didn't keep the body, so every waiter asks for its own
+	if f.entry.tooLarge {
+		return c.bypass(req, rt.next)
+	}
+
+	return f.entry.response(req, "MISS", 0), nil
+}
+
+// bypass sends req upstream and leaves the response alone.
+func (c *Cache) bypass(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	c.mu.Lock()
+	c.stats.Uncached++
+	c.mu.Unlock()
+
+	return next.RoundTrip(req)
+}
+
+// join returns the flight for key, starting one if there's none yet;
+// shared says if it was already running. c.mu must be held.
+func (c *Cache) join(key string, req *http.Request, next http.RoundTripper) (*flight, bool) {
+	if f, ok := c.flights[key]; ok {
+		return f, true
+	}
+
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+
+	// the upstream call doesn't belong to the request that started it:
+	// others may be waiting for it when that one gets cancelled
+	req = req.Clone(context.WithoutCancel(req.Context()))
+
+	go func() {
+		f.entry, f.err = c.fetch(key, req, next)
+
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+
+		close(f.done)
+	}()
+
+	return f, false
+}
+
+func (c *Cache) fetch(key string, req *http.Request, next http.RoundTripper) (*entry, error) {
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	// one byte more than we keep tells us if there's too much
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > c.opts.MaxBodyBytes {
+		e := &entry{key: key, stored: c.now(), tooLarge: true}
+		c.store(e)
+
+		return e, nil
+	}
+
+	e := &entry{
+		key:    key,
+		status: resp.StatusCode,
+		header: resp.Header.Clone(),
+		body:   body,
+		stored: c.now(),
+	}
+
+	if resp.StatusCode == http.StatusOK && shareable(resp.Header) {
+		c.store(e)
+	}
+
+	return e, nil
+}
+
+// shareable says if a response can be given to other clients than the
+// one that asked: it isn't private, and it doesn't depend on a request
+// header other than the Accept that's part of the key.
+func shareable(h http.Header) bool {
+	cc := h.Get("Cache-Control")
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return false
+	}
+
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			switch http.CanonicalHeaderKey(strings.TrimSpace(name)) {
+			case "Accept", "Accept-Encoding", "":
+			default:
+				return false // "*" too
+			}
+		}
+	}
+
+	return true
+}
+
+// revalidate refreshes a stale entry. If that fails we keep serving the
+// stale one until it expires for good.
+func (c *Cache) revalidate(key string, req *http.Request, next http.RoundTripper, stale *entry) {
+	c.mu.Lock()
+	f, _ := c.join(key, req, next)
+	c.mu.Unlock()
+
+	<-f.done
+
+	c.mu.Lock()
+	stale.revalidating = false
+	c.mu.Unlock()
+}
+
+// store adds e, replacing an older entry for the same key, and evicts the
+// least recently used entries until we're within the limits.
+func (c *Cache) store(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[e.key]; ok {
+		c.remove(el)
+	}
+
+	c.items[e.key] = c.lru.PushFront(e)
+	c.stats.Bytes += int64(len(e.body))
+
+	for c.lru.Len() > c.opts.MaxEntries || c.stats.Bytes > c.opts.MaxBytes {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.items, e.key)
+	c.stats.Bytes -= int64(len(e.body))
+}
+
+// response makes a new http.Response from the entry for every caller,
+// since each of them reads (and closes) its own body.
+func (e *entry) response(req *http.Request, status string, age time.Duration) *http.Response {
+	h := e.header.Clone()
+	h.Set("X-Cache", status)
+
+	if age > 0 {
+		h.Set("Age", strconv.Itoa(int(age.Seconds())))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
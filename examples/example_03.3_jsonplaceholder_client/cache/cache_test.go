@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// upstream answers /{path} with "{path} #{call number}", /big with
+// that and 100 bytes more, and /private and /vary with headers that
+// keep them out of a shared cache.
+func upstream(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+			return
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/vary":
+			w.Header().Set("Vary", "Accept, User-Agent")
+		}
+
+		fmt.Fprintf(w, "%s #%d", r.URL.Path, n)
+
+		if r.URL.Path == "/big" {
+			w.Write(make([]byte, 100))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+func get(t *testing.T, client *http.Client, url string) (string, string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	return string(b), resp.Header.Get("X-Cache")
+}
+
+func TestHitAndExpiry(t *testing.T) {
+	srv, calls := upstream(t)
+
+	now := time.Now()
+	c := New(Options{TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	client := &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	if body, status := get(t, client, srv.URL+"/a"); body != "/a #1" || status != "MISS" {
+		t.Errorf("first: %q %s", body, status)
+	}
+
+	if body, status := get(t, client, srv.URL+"/a"); body != "/a #1" || status != "HIT" {
+		t.Errorf("second: %q %s", body, status)
+	}
+
+	now = now.Add(time.Minute)
+
+	if body, status := get(t, client, srv.URL+"/a"); body != "/a #2" || status != "MISS" {
+		t.Errorf("expired: %q %s", body, status)
+	}
+
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 2 || s.Entries != 1 || calls.Load() != 2 {
+		t.Errorf("unexpected stats %+v after %d calls", s, calls.Load())
+	}
+}
+
+func TestNotCached(t *testing.T) {
+	srv, calls := upstream(t)
+
+	c := New(Options{})
+	client := &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	get(t, client, srv.URL+"/missing")
+	get(t, client, srv.URL+"/missing")
+
+	client.Post(srv.URL+"/a", "text/plain", nil)
+	client.Post(srv.URL+"/a", "text/plain", nil)
+
+	if calls.Load() != 4 {
+		t.Errorf("got %d upstream calls, want 4", calls.Load())
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	srv, calls := upstream(t)
+
+	var mu sync.Mutex
+
+	now := time.Now()
+	c := New(Options{TTL: time.Minute, StaleWhileRevalidate: time.Minute})
+	c.now = func() time.Time { mu.Lock(); defer mu.Unlock(); return now }
+
+	client := &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	get(t, client, srv.URL+"/a")
+
+	mu.Lock()
+	now = now.Add(90 * time.Second)
+	mu.Unlock()
+
+	// the stale copy comes back at once, a refresh starts behind it
+	if body, status := get(t, client, srv.URL+"/a"); body != "/a #1" || status != "STALE" {
+		t.Errorf("stale: %q %s", body, status)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		body, status := get(t, client, srv.URL+"/a")
+
+		if body == "/a #2" && status == "HIT" {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("never revalidated, still %q %s", body, status)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if calls.Load() != 2 || c.Stats().Revalidations != 1 {
+		t.Errorf("got %d calls, stats %+v", calls.Load(), c.Stats())
+	}
+}
+
+func TestSingleflight(t *testing.T) {
+	var calls atomic.Int32
+
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		io.WriteString(w, "slow")
+	}))
+	defer srv.Close()
+
+	c := New(Options{})
+	client := &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if body, _ := get(t, client, srv.URL); body != "slow" {
+				t.Errorf("got %q", body)
+			}
+		}()
+	}
+
+	// wait until all ten are queued behind the first one
+	for c.Stats().Misses+c.Stats().Shared < 10 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("got %d upstream calls, want 1", calls.Load())
+	}
+}
+
+func TestEviction(t *testing.T) {
+	srv, calls := upstream(t)
+
+	c := New(Options{MaxEntries: 2})
+	client := &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	get(t, client, srv.URL+"/a")
+	get(t, client, srv.URL+"/b")
+	get(t, client, srv.URL+"/a") // a is now the most recently used
+	get(t, client, srv.URL+"/c") // so b goes
+
+	if _, status := get(t, client, srv.URL+"/a"); status != "HIT" {
+		t.Errorf("a was evicted")
+	}
+
+	if _, status := get(t, client, srv.URL+"/b"); status != "MISS" {
+		t.Errorf("b was kept")
+	}
+
+	if s := c.Stats(); s.Entries != 2 || s.Evictions != 2 || calls.Load() != 4 {
+		t.Errorf("unexpected stats %+v after %d calls", s, calls.Load())
+	}
+
+	// a byte limit smaller than two bodies keeps only one
+	c = New(Options{MaxBytes: 7})
+	client = &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	get(t, client, srv.URL+"/a")
+	get(t, client, srv.URL+"/b")
+
+	if s := c.Stats(); s.Entries != 1 || s.Bytes > 7 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	srv, calls := upstream(t)
+
+	c := New(Options{})
+	client := &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	for _, header := range []string{"Authorization", "Cookie"} {
+		for range 2 {
+			req, _ := http.NewRequest("GET", srv.URL+"/a", nil)
+			req.Header.Set(header, "secret")
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if status := resp.Header.Get("X-Cache"); status != "" {
+				t.Errorf("%s: X-Cache %s", header, status)
+			}
+		}
+	}
+
+	if s := c.Stats(); s.Entries != 0 || s.Uncached != 4 || calls.Load() != 4 {
+		t.Errorf("unexpected stats %+v after %d calls", s, calls.Load())
+	}
+
+	// and nothing fetched with them is given to a request without
+	if body, status := get(t, client, srv.URL+"/a"); body != "/a #5" || status != "MISS" {
+		t.Errorf("without: %q %s", body, status)
+	}
+}
+
+func TestNotShareable(t *testing.T) {
+	srv, calls := upstream(t)
+
+	c := New(Options{})
+	client := &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	for _, path := range []string{"/private", "/vary"} {
+		get(t, client, srv.URL+path)
+
+		if _, status := get(t, client, srv.URL+path); status != "MISS" {
+			t.Errorf("%s: %s", path, status)
+		}
+	}
+
+	if s := c.Stats(); s.Entries != 0 || calls.Load() != 4 {
+		t.Errorf("unexpected stats %+v after %d calls", s, calls.Load())
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	srv, calls := upstream(t)
+
+	now := time.Now()
+	c := New(Options{TTL: time.Minute, MaxBodyBytes: 50})
+	c.now = func() time.Time { return now }
+
+	client := &http.Client{Transport: c.Wrap(http.DefaultTransport)}
+
+	// every request gets the whole body, straight from upstream, the first
+	// after the call that found it too large
+	for i := range 3 {
+		body, status := get(t, client, srv.URL+"/big")
+
+		if want := fmt.Sprintf("/big #%d", i+2); len(body) != len(want)+100 || body[:len(want)] != want || status != "" {
+			t.Errorf("request %d: %q %s", i, body, status)
+		}
+	}
+
+	// the others didn't need to find out again
+	if s := c.Stats(); s.Misses != 1 || s.Uncached != 3 || s.Bytes != 0 || calls.Load() != 4 {
+		t.Errorf("unexpected stats %+v after %d calls", s, calls.Load())
+	}
+
+	// small ones are still kept
+	get(t, client, srv.URL+"/a")
+
+	if _, status := get(t, client, srv.URL+"/a"); status != "HIT" {
+		t.Errorf("small: %s", status)
+	}
+}
@@ -0,0 +1,245 @@
+// Package jsonplaceholder is a typed client for the fake REST API at
+// https://jsonplaceholder.typicode.com, which the web client and server
+// examples use as their upstream.
+package jsonplaceholder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultBaseURL is the public JSONPlaceholder service.
+const DefaultBaseURL = "https://jsonplaceholder.typicode.com"
+
+// Client talks to a JSONPlaceholder-compatible server. Point BaseURL at an
+// httptest.Server (or the fake package) to run without the network.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	Todos    *Resource[Todo]
+	Posts    *Resource[Post]
+	Comments *Resource[Comment]
+	Users    *Resource[User]
+	Albums   *Resource[Album]
+	Photos   *Resource[Photo]
+}
+
+// NewClient returns a client for baseURL ("" means DefaultBaseURL) using
+// httpClient (nil means http.DefaultClient).
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	c := &Client{BaseURL: baseURL, HTTPClient: httpClient}
+
+	c.Todos = &Resource[Todo]{c: c, path: "todos"}
+	c.Posts = &Resource[Post]{c: c, path: "posts"}
+	c.Comments = &Resource[Comment]{c: c, path: "comments"}
+	c.Users = &Resource[User]{c: c, path: "users"}
+	c.Albums = &Resource[Album]{c: c, path: "albums"}
+	c.Photos = &Resource[Photo]{c: c, path: "photos"}
+
+	return c
+}
+
+// Do sends a request to path (relative to BaseURL) with in encoded as the
+// JSON body (if not nil) and decodes the response into out (if not nil).
+// Any non-2xx response is returned as an *APIError.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	_, err := c.do(ctx, method, path, query, in, out)
+	return err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) (http.Header, error) {
+	u := strings.TrimRight(c.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("jsonplaceholder: encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close() // Close so that the socket can be reused
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return resp.Header, &APIError{
+			Method:     method,
+			URL:        u,
+			StatusCode: resp.StatusCode,
+			Body:       string(snippet),
+		}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.Header, fmt.Errorf("jsonplaceholder: decode %s %s: %w", method, u, err)
+		}
+	}
+
+	return resp.Header, nil
+}
+
+// ListOptions select, sort and paginate the items of a List call, using
+// the query parameters the JSONPlaceholder (json-server) backend understands.
+type ListOptions struct {
+	Filter url.Values // field filters, e.g. userId=1, completed=true or title_like=^a
+	Search string     // full-text search over all fields (q=)
+	Page   int        // 1-based page number, needs Limit
+	Limit  int        // items per page
+	Sort   string     // field to sort by
+	Order  string     // "asc" or "desc"
+}
+
+// Where adds a field filter and returns o, so calls can be chained:
+//
+//	(&ListOptions{}).Where("userId", 1).Where("completed", false)
+func (o *ListOptions) Where(field string, value any) *ListOptions {
+	if o.Filter == nil {
+		o.Filter = url.Values{}
+	}
+
+	o.Filter.Add(field, fmt.Sprint(value))
+	return o
+}
+
+func (o *ListOptions) values() url.Values {
+	q := url.Values{}
+
+	if o == nil {
+		return q
+	}
+
+	for k, vs := range o.Filter {
+		q[k] = append([]string(nil), vs...)
+	}
+
+	if o.Search != "" {
+		q.Set("q", o.Search)
+	}
+
+	if o.Page > 0 {
+		q.Set("_page", strconv.Itoa(o.Page))
+	}
+
+	if o.Limit > 0 {
+		q.Set("_limit", strconv.Itoa(o.Limit))
+	}
+
+	if o.Sort != "" {
+		q.Set("_sort", o.Sort)
+	}
+
+	if o.Order != "" {
+		q.Set("_order", o.Order)
+	}
+
+	return q
+}
+
+// Page is one page of a list. Total counts all the matching items, not
+// only those on this page.
+type Page[T any] struct {
+	Items []T
+	Total int
+}
+
+// Resource gives typed access to one collection, such as /todos.
+type Resource[T any] struct {
+	c    *Client
+	path string
+}
+
+// Get fetches one item; a missing item gives an error matching ErrNotFound.
+func (r *Resource[T]) Get(ctx context.Context, id int) (T, error) {
+	var v T
+	err := r.c.Do(ctx, http.MethodGet, r.itemPath(id), nil, nil, &v)
+	return v, err
+}
+
+// List fetches the items matching opts (nil means all of them).
+func (r *Resource[T]) List(ctx context.Context, opts *ListOptions) (Page[T], error) {
+	var page Page[T]
+
+	header, err := r.c.do(ctx, http.MethodGet, r.path, opts.values(), nil, &page.Items)
+	if err != nil {
+		return page, err
+	}
+
+	// the server only reports the total when paginating
+	page.Total = len(page.Items)
+
+	if n, err := strconv.Atoi(header.Get("X-Total-Count")); err == nil {
+		page.Total = n
+	}
+
+	return page, nil
+}
+
+// Create posts a new item and returns it as stored by the server (with its ID).
+func (r *Resource[T]) Create(ctx context.Context, v T) (T, error) {
+	var created T
+	err := r.c.Do(ctx, http.MethodPost, r.path, nil, v, &created)
+	return created, err
+}
+
+// Update replaces the item with the given id.
+func (r *Resource[T]) Update(ctx context.Context, id int, v T) (T, error) {
+	var updated T
+	err := r.c.Do(ctx, http.MethodPut, r.itemPath(id), nil, v, &updated)
+	return updated, err
+}
+
+// Patch changes only the given fields (by JSON name) of the item.
+func (r *Resource[T]) Patch(ctx context.Context, id int, fields map[string]any) (T, error) {
+	var updated T
+	err := r.c.Do(ctx, http.MethodPatch, r.itemPath(id), nil, fields, &updated)
+	return updated, err
+}
+
+// Delete removes the item with the given id.
+func (r *Resource[T]) Delete(ctx context.Context, id int) error {
+	return r.c.Do(ctx, http.MethodDelete, r.itemPath(id), nil, nil, nil)
+}
+
+func (r *Resource[T]) itemPath(id int) string {
+	return r.path + "/" + strconv.Itoa(id)
+}
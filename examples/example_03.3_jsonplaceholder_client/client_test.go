@@ -0,0 +1,139 @@
+package jsonplaceholder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/todos/1" {
+			http.NotFound(w, r)
+			return
+		}
+
+		io.WriteString(w, `{"userId":1,"id":1,"title":"delectus aut autem","completed":false}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, srv.Client())
+
+	todo, err := c.Todos.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Todo{UserID: 1, ID: 1, Title: "delectus aut autem"}
+
+	if todo != want {
+		t.Errorf("got %#v, want %#v", todo, want)
+	}
+
+	_, err = c.Todos.Get(context.Background(), 2)
+
+	var apiErr *APIError
+
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, want a 404 APIError", err)
+	}
+}
+
+func TestListQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "_limit=2&_order=desc&_page=3&_sort=id&userId=1"
+
+		if got := r.URL.RawQuery; r.URL.Path != "/todos" || got != want {
+			t.Errorf("got query %q, want %q", got, want)
+		}
+
+		w.Header().Set("X-Total-Count", "20")
+		io.WriteString(w, `[{"id":6},{"id":5}]`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, nil)
+	opts := (&ListOptions{Page: 3, Limit: 2, Sort: "id", Order: "desc"}).Where("userId", 1)
+
+	page, err := c.Todos.List(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Total != 20 || len(page.Items) != 2 || page.Items[0].ID != 6 {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/posts" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+
+		var p Post
+
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+
+		p.ID = 101
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(p)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, nil)
+
+	p, err := c.Posts.Create(context.Background(), Post{UserID: 1, Title: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.ID != 101 || p.Title != "hi" {
+		t.Errorf("got %#v", p)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	err := NewClient(srv.URL, nil).Users.Delete(context.Background(), 1)
+
+	var apiErr *APIError
+
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 500 || apiErr.Body != "oops\n" {
+		t.Errorf("got %#v", err)
+	}
+
+	if errors.Is(err, ErrNotFound) {
+		t.Error("a 500 is not ErrNotFound")
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := NewClient(srv.URL, nil).Photos.Get(ctx, 1)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"jsonplaceholder/fake"
+)
+
+// Runs the fake JSONPlaceholder on its own, so the web client and server
+// examples can work offline, e.g.
+//
+//	go run ./cmd/fakeserver -addr :8081 -latency 200ms -error-rate 0.1
+//	curl localhost:8081/todos/1
+
+func main() {
+	var faults fake.Faults
+
+	addr := flag.String("addr", ":8081", "address to listen on")
+	flag.DurationVar(&faults.Latency, "latency", 0, "delay added to every request")
+	flag.Float64Var(&faults.ErrorRate, "error-rate", 0, "fraction of requests that fail (0..1)")
+	flag.IntVar(&faults.ErrorStatus, "error-status", 500, "status code of the failed requests")
+	flag.Parse()
+
+	srv := fake.New(fake.DefaultFixtures())
+	srv.SetFaults(faults)
+
+	log.Printf("Fake JSONPlaceholder listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
@@ -0,0 +1,26 @@
+package jsonplaceholder
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotFound matches (with errors.Is) an APIError for a 404 response.
+var ErrNotFound = errors.New("jsonplaceholder: not found")
+
+// APIError is returned for any response that isn't a 2xx.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string // the start of the response body, for debugging
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("jsonplaceholder: %s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,136 @@
+package fake
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"jsonplaceholder"
+)
+
+// Fixtures is the data a Server starts with (and returns to on Reset).
+type Fixtures struct {
+	Users    []jsonplaceholder.User
+	Todos    []jsonplaceholder.Todo
+	Posts    []jsonplaceholder.Post
+	Comments []jsonplaceholder.Comment
+	Albums   []jsonplaceholder.Album
+	Photos   []jsonplaceholder.Photo
+}
+
+var words = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit
+	sed do eiusmod tempor incididunt ut labore et dolore magna aliqua enim ad
+	minim veniam quis nostrud exercitation ullamco laboris nisi aliquip ex ea
+	commodo consequat duis aute irure in reprehenderit voluptate velit esse
+	cillum fugiat nulla pariatur excepteur sint occaecat cupidatat non proident
+	sunt culpa qui officia deserunt mollit anim id est laborum`)
+
+var names = []struct{ first, last string }{
+	{"Leanne", "Graham"}, {"Ervin", "Howell"}, {"Clementine", "Bauch"},
+	{"Patricia", "Lebsack"}, {"Chelsey", "Dietrich"}, {"Dennis", "Schulist"},
+	{"Kurtis", "Weissnat"}, {"Nicholas", "Runolfsdottir"}, {"Glenna", "Reichert"},
+	{"Clementina", "DuBuque"},
+}
+
+// DefaultFixtures returns the same number of items as the real service
+// (10 users with 20 todos, 10 posts and 10 albums each, 5 comments per
+// post and 50 photos per album). The text is random but always the same.
+func DefaultFixtures() Fixtures {
+	rnd := rand.New(rand.NewSource(1))
+
+	sentence := func(n int) string {
+		s := make([]string, n)
+		for i := range s {
+			s[i] = words[rnd.Intn(len(words))]
+		}
+		return strings.Join(s, " ")
+	}
+
+	var fx Fixtures
+
+	for u, n := range names {
+		userID := u + 1
+		username := strings.ToLower(n.first[:1] + n.last)
+
+		fx.Users = append(fx.Users, jsonplaceholder.User{
+			ID:       userID,
+			Name:     n.first + " " + n.last,
+			Username: username,
+			Email:    username + "@example.com",
+			Address: jsonplaceholder.Address{
+				Street:  sentence(2),
+				Suite:   fmt.Sprintf("Apt. %d", 100+rnd.Intn(900)),
+				City:    sentence(1),
+				Zipcode: fmt.Sprintf("%05d", rnd.Intn(100000)),
+				Geo: jsonplaceholder.Geo{
+					Lat: fmt.Sprintf("%.4f", rnd.Float64()*180-90),
+					Lng: fmt.Sprintf("%.4f", rnd.Float64()*360-180),
+				},
+			},
+			Phone:   fmt.Sprintf("1-770-736-%04d", rnd.Intn(10000)),
+			Website: username + ".org",
+			Company: jsonplaceholder.Company{
+				Name:        n.last + " LLC",
+				CatchPhrase: sentence(4),
+				BS:          sentence(3),
+			},
+		})
+
+		for i := 0; i < 20; i++ {
+			fx.Todos = append(fx.Todos, jsonplaceholder.Todo{
+				UserID:    userID,
+				ID:        len(fx.Todos) + 1,
+				Title:     sentence(3 + rnd.Intn(5)),
+				Completed: rnd.Intn(2) == 0,
+			})
+		}
+
+		for i := 0; i < 10; i++ {
+			fx.Posts = append(fx.Posts, jsonplaceholder.Post{
+				UserID: userID,
+				ID:     len(fx.Posts) + 1,
+				Title:  sentence(4 + rnd.Intn(4)),
+				Body:   sentence(20),
+			})
+		}
+
+		for i := 0; i < 10; i++ {
+			fx.Albums = append(fx.Albums, jsonplaceholder.Album{
+				UserID: userID,
+				ID:     len(fx.Albums) + 1,
+				Title:  sentence(3 + rnd.Intn(4)),
+			})
+		}
+	}
+
+	for _, p := range fx.Posts {
+		for i := 0; i < 5; i++ {
+			id := len(fx.Comments) + 1
+
+			fx.Comments = append(fx.Comments, jsonplaceholder.Comment{
+				PostID: p.ID,
+				ID:     id,
+				Name:   sentence(4),
+				Email:  fmt.Sprintf("%s%d@example.net", words[rnd.Intn(len(words))], id),
+				Body:   sentence(15),
+			})
+		}
+	}
+
+	for _, a := range fx.Albums {
+		for i := 0; i < 50; i++ {
+			id := len(fx.Photos) + 1
+			color := fmt.Sprintf("%06x", rnd.Intn(1<<24))
+
+			fx.Photos = append(fx.Photos, jsonplaceholder.Photo{
+				AlbumID:      a.ID,
+				ID:           id,
+				Title:        sentence(5),
+				URL:          "https://via.placeholder.com/600/" + color,
+				ThumbnailURL: "https://via.placeholder.com/150/" + color,
+			})
+		}
+	}
+
+	return fx
+}
@@ -0,0 +1,506 @@
+// Package fake is an in-process stand-in for JSONPlaceholder. It serves the
+// same routes from fixture data, keeps POST / PUT / PATCH / DELETE changes
+// in memory and can add latency or fail requests on purpose:
+//
+//	srv := httptest.NewServer(fake.New(fake.DefaultFixtures()))
+//	defer srv.Close()
+//
+//	client := jsonplaceholder.NewClient(srv.URL, nil)
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// object is one item as JSON would decode it, which lets us filter, sort
+// and patch any resource the same way.
+type object = map[string]any
+
+// parents lists, for each nested route such as /users/1/todos, the field
+// that links the child to its parent.
+var parents = map[string]map[string]string{
+	"users":  {"todos": "userId", "posts": "userId", "albums": "userId"},
+	"posts":  {"comments": "postId"},
+	"albums": {"photos": "albumId"},
+}
+
+// Faults make the server misbehave. The zero value means no faults.
+type Faults struct {
+	Latency     time.Duration // added to every request
+	ErrorRate   float64       // fraction (0..1) of requests that fail
+	ErrorStatus int           // status of the failures, 500 when zero
+}
+
+// Server is an http.Handler serving JSONPlaceholder's API. It's safe for
+// concurrent use.
+type Server struct {
+	fixtures Fixtures
+
+	mu       sync.Mutex
+	data     map[string][]object
+	faults   Faults
+	failNext []int // statuses for the next requests, used before ErrorRate
+	rnd      *rand.Rand
+}
+
+// New returns a server holding a copy of fx.
+func New(fx Fixtures) *Server {
+	s := &Server{fixtures: fx, rnd: rand.New(rand.NewSource(1))}
+	s.Reset()
+	return s
+}
+
+// Reset throws away all changes and goes back to the fixtures.
+func (s *Server) Reset() {
+	data := map[string][]object{}
+
+	add := func(name string, items any) {
+		var objs []object
+
+		// the round trip through JSON gives us the wire field names
+		b, _ := json.Marshal(items)
+		json.Unmarshal(b, &objs)
+
+		if objs == nil {
+			objs = []object{}
+		}
+
+		data[name] = objs
+	}
+
+	add("users", s.fixtures.Users)
+	add("todos", s.fixtures.Todos)
+	add("posts", s.fixtures.Posts)
+	add("comments", s.fixtures.Comments)
+	add("albums", s.fixtures.Albums)
+	add("photos", s.fixtures.Photos)
+
+	s.mu.Lock()
+	s.data = data
+	s.mu.Unlock()
+}
+
+// SetFaults changes how the server misbehaves from now on.
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	s.faults = f
+	s.mu.Unlock()
+}
+
+// FailNext makes the next n requests fail with status; it's the
+// deterministic alternative to Faults.ErrorRate for tests.
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.failNext = append(s.failNext, status)
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := s.fault(r); status != 0 {
+		writeJSON(w, status, object{"error": http.StatusText(status)})
+		return
+	}
+
+	// the body is read, and the answer written, without the lock, so a
+	// slow client holds up nobody else
+	var body object
+
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		body = decode(w, r)
+	}
+
+	status, v := s.serve(w.Header(), r, body)
+	writeJSON(w, status, v)
+}
+
+// serve answers r, whose body, if it has one, is already decoded: nil if
+// it's not a JSON object. The values it returns are never changed once
+// they're stored, and the slices are fresh, so they can be written out
+// after the lock is released.
+func (s *Server) serve(h http.Header, r *http.Request, body object) (int, any) {
+	segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data[segs[0]]; !ok {
+		return http.StatusNotFound, object{}
+	}
+
+	switch len(segs) {
+	case 1:
+		return s.collection(h, r, body, segs[0], "", 0)
+
+	case 2:
+		id, err := strconv.Atoi(segs[1])
+		if err != nil {
+			return http.StatusNotFound, object{}
+		}
+
+		return s.item(h, r, body, segs[0], id)
+
+	case 3:
+		field, ok := parents[segs[0]][segs[2]]
+		id, err := strconv.Atoi(segs[1])
+
+		if !ok || err != nil {
+			return http.StatusNotFound, object{}
+		}
+
+		return s.collection(h, r, body, segs[2], field, id)
+	}
+
+	return http.StatusNotFound, object{}
+}
+
+// fault waits for the configured latency and decides whether
+// the request should fail, returning the status to fail with.
+func (s *Server) fault(r *http.Request) int {
+	s.mu.Lock()
+	f := s.faults
+	status := 0
+
+	if len(s.failNext) > 0 {
+		status, s.failNext = s.failNext[0], s.failNext[1:]
+	} else if f.ErrorRate > 0 && s.rnd.Float64() < f.ErrorRate {
+		status = f.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+	}
+	s.mu.Unlock()
+
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-r.Context().Done():
+		}
+	}
+
+	return status
+}
+
+// collection handles /todos and nested routes like /users/1/todos,
+// where field (userId) must match parentID.
+func (s *Server) collection(h http.Header, r *http.Request, obj object, name, field string, parentID int) (int, any) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		q := r.URL.Query()
+
+		if field != "" {
+			q.Set(field, strconv.Itoa(parentID))
+		}
+
+		items, err := query(s.data[name], q)
+		if err != nil {
+			return http.StatusBadRequest, object{"error": err.Error()}
+		}
+
+		total := len(items)
+		items = paginate(items, q)
+
+		if q.Has("_page") || q.Has("_limit") {
+			h.Set("X-Total-Count", strconv.Itoa(total))
+		}
+
+		return http.StatusOK, items
+
+	case http.MethodPost:
+		if obj == nil {
+			return badBody()
+		}
+
+		if field != "" {
+			obj[field] = float64(parentID)
+		}
+
+		obj["id"] = float64(s.nextID(name))
+		s.data[name] = append(s.data[name], obj)
+
+		h.Set("Location", fmt.Sprintf("/%s/%v", name, obj["id"]))
+		return http.StatusCreated, obj
+	}
+
+	h.Set("Allow", "GET, HEAD, POST")
+	return http.StatusMethodNotAllowed, object{}
+}
+
+// item handles /todos/1.
+func (s *Server) item(h http.Header, r *http.Request, obj object, name string, id int) (int, any) {
+	i := s.index(name, id)
+
+	if i < 0 {
+		return http.StatusNotFound, object{}
+	}
+
+	items := s.data[name]
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return http.StatusOK, items[i]
+
+	case http.MethodPut:
+		if obj == nil {
+			return badBody()
+		}
+
+		obj["id"] = float64(id)
+		items[i] = obj
+
+		return http.StatusOK, obj
+
+	case http.MethodPatch:
+		if obj == nil {
+			return badBody()
+		}
+
+		// copy first, so an earlier response can't see the change
+		merged := make(object, len(items[i]))
+
+		for k, v := range items[i] {
+			merged[k] = v
+		}
+
+		for k, v := range obj {
+			if k != "id" {
+				merged[k] = v
+			}
+		}
+
+		items[i] = merged
+
+		return http.StatusOK, merged
+
+	case http.MethodDelete:
+		s.data[name] = append(items[:i:i], items[i+1:]...)
+
+		return http.StatusOK, object{}
+	}
+
+	h.Set("Allow", "GET, HEAD, PUT, PATCH, DELETE")
+	return http.StatusMethodNotAllowed, object{}
+}
+
+func (s *Server) index(name string, id int) int {
+	for i, obj := range s.data[name] {
+		if n, ok := obj["id"].(float64); ok && int(n) == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *Server) nextID(name string) int {
+	max := 0
+
+	for _, obj := range s.data[name] {
+		if n, ok := obj["id"].(float64); ok && int(n) > max {
+			max = int(n)
+		}
+	}
+
+	return max + 1
+}
+
+// query filters and sorts items following the json-server conventions:
+// field=value (repeat for OR), field_ne, field_gte, field_lte, field_like
+// (a regular expression), q (full-text), _sort and _order.
+func query(items []object, q map[string][]string) ([]object, error) {
+	out := make([]object, 0, len(items))
+
+	type test func(object) bool
+
+	var tests []test
+
+	for key, values := range q {
+		if strings.HasPrefix(key, "_") || key == "q" {
+			continue
+		}
+
+		field, op := key, ""
+
+		for _, suffix := range []string{"_ne", "_gte", "_lte", "_like"} {
+			if strings.HasSuffix(key, suffix) {
+				field, op = strings.TrimSuffix(key, suffix), suffix
+				break
+			}
+		}
+
+		switch op {
+		case "":
+			tests = append(tests, func(o object) bool {
+				for _, v := range values {
+					if text(o[field]) == v {
+						return true
+					}
+				}
+				return false
+			})
+
+		case "_ne":
+			tests = append(tests, func(o object) bool {
+				for _, v := range values {
+					if text(o[field]) == v {
+						return false
+					}
+				}
+				return true
+			})
+
+		case "_gte", "_lte":
+			limit, err := strconv.ParseFloat(values[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %q", key, values[0])
+			}
+
+			tests = append(tests, func(o object) bool {
+				n, ok := o[field].(float64)
+				if op == "_gte" {
+					return ok && n >= limit
+				}
+				return ok && n <= limit
+			})
+
+		case "_like":
+			re, err := regexp.Compile("(?i)" + values[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", key, err)
+			}
+
+			tests = append(tests, func(o object) bool {
+				return re.MatchString(text(o[field]))
+			})
+		}
+	}
+
+	if term := strings.ToLower(strings.Join(q["q"], " ")); term != "" {
+		tests = append(tests, func(o object) bool {
+			for _, v := range o {
+				if s, ok := v.(string); ok && strings.Contains(strings.ToLower(s), term) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+items:
+	for _, obj := range items {
+		for _, t := range tests {
+			if !t(obj) {
+				continue items
+			}
+		}
+
+		out = append(out, obj)
+	}
+
+	if fields := q["_sort"]; len(fields) > 0 && fields[0] != "" {
+		field := fields[0]
+		desc := len(q["_order"]) > 0 && strings.EqualFold(q["_order"][0], "desc")
+
+		sort.SliceStable(out, func(i, j int) bool {
+			a, b := out[i][field], out[j][field]
+
+			if desc {
+				a, b = b, a
+			}
+
+			if x, ok := a.(float64); ok {
+				if y, ok := b.(float64); ok {
+					return x < y
+				}
+			}
+
+			return text(a) < text(b)
+		})
+	}
+
+	return out, nil
+}
+
+// paginate applies _page and _limit (json-server defaults to 10 a page)
+// or _start and _end.
+func paginate(items []object, q map[string][]string) []object {
+	get := func(key string, def int) int {
+		if vs := q[key]; len(vs) > 0 {
+			if n, err := strconv.Atoi(vs[0]); err == nil && n >= 0 {
+				return n
+			}
+		}
+		return def
+	}
+
+	start, end := get("_start", 0), get("_end", len(items))
+
+	if limit := get("_limit", -1); limit >= 0 || len(q["_page"]) > 0 {
+		if limit < 0 {
+			limit = 10
+		}
+
+		page := get("_page", 1)
+		if page < 1 {
+			page = 1
+		}
+
+		// past the last page there's nothing, which is also where a page
+		// and limit big enough to overflow (page-1)*limit would start
+		start, end = len(items), len(items)
+
+		if limit > 0 && page-1 <= len(items)/limit {
+			start = (page - 1) * limit
+			end = start + min(limit, len(items)-start)
+		}
+	}
+
+	start = min(start, len(items))
+	end = min(max(end, start), len(items))
+
+	return items[start:end]
+}
+
+func text(v any) string {
+	if v == nil {
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
+
+// decode reads the JSON object in the body of r, nil if there's none.
+func decode(w http.ResponseWriter, r *http.Request) object {
+	var obj object
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&obj); err != nil {
+		return nil
+	}
+
+	return obj
+}
+
+func badBody() (int, any) {
+	return http.StatusBadRequest, object{"error": "body must be a JSON object"}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,168 @@
+package fake_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"jsonplaceholder"
+	"jsonplaceholder/fake"
+)
+
+func setup(t *testing.T) (*fake.Server, *jsonplaceholder.Client) {
+	t.Helper()
+
+	f := fake.New(fake.DefaultFixtures())
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	return f, jsonplaceholder.NewClient(srv.URL, srv.Client())
+}
+
+func TestRead(t *testing.T) {
+	_, c := setup(t)
+	ctx := context.Background()
+
+	todo, err := c.Todos.Get(ctx, 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if todo.ID != 21 || todo.UserID != 2 || todo.Title == "" {
+		t.Errorf("unexpected todo %#v", todo)
+	}
+
+	if _, err := c.Users.Get(ctx, 11); !errors.Is(err, jsonplaceholder.ErrNotFound) {
+		t.Errorf("got %v, want not found", err)
+	}
+
+	page, err := c.Todos.List(ctx, (&jsonplaceholder.ListOptions{Page: 2, Limit: 5, Sort: "id", Order: "desc"}).Where("userId", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Total != 20 || len(page.Items) != 5 || page.Items[0].ID != 55 {
+		t.Errorf("unexpected page: total %d, %d items, first %+v", page.Total, len(page.Items), page.Items[0])
+	}
+
+	var comments []jsonplaceholder.Comment
+
+	if err := c.Do(ctx, http.MethodGet, "posts/7/comments", nil, nil, &comments); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(comments) != 5 || comments[0].PostID != 7 {
+		t.Errorf("unexpected comments %+v", comments)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	f, c := setup(t)
+	ctx := context.Background()
+
+	todo, err := c.Todos.Create(ctx, jsonplaceholder.Todo{UserID: 1, Title: "write tests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if todo.ID != 201 {
+		t.Errorf("got id %d, want 201", todo.ID)
+	}
+
+	if _, err := c.Todos.Patch(ctx, 201, map[string]any{"completed": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := c.Todos.Get(ctx, 201); !got.Completed || got.Title != "write tests" {
+		t.Errorf("patch lost: %#v", got)
+	}
+
+	if err := c.Todos.Delete(ctx, 201); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Todos.Get(ctx, 201); !errors.Is(err, jsonplaceholder.ErrNotFound) {
+		t.Errorf("got %v after delete", err)
+	}
+
+	if err := c.Todos.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Reset()
+
+	if _, err := c.Todos.Get(ctx, 1); err != nil {
+		t.Errorf("reset didn't restore todo 1: %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	f, c := setup(t)
+
+	f.FailNext(1, http.StatusServiceUnavailable)
+
+	var apiErr *jsonplaceholder.APIError
+
+	if _, err := c.Posts.Get(context.Background(), 1); !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+		t.Errorf("got %v, want a 503", err)
+	}
+
+	if _, err := c.Posts.Get(context.Background(), 1); err != nil {
+		t.Errorf("only one request should fail, got %v", err)
+	}
+
+	f.SetFaults(fake.Faults{Latency: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Posts.Get(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a timeout", err)
+	}
+}
+
+// Any page and limit, however big or negative, give a page of the todos
+// or none of them, never a panic.
+func TestPaginate(t *testing.T) {
+	fx := fake.DefaultFixtures()
+	f := fake.New(fx)
+	n := len(fx.Todos)
+
+	for _, c := range []struct {
+		query string
+		want  int
+	}{
+		{"_page=1&_limit=3", 3},
+		{"_page=2", 10},
+		{"_limit=0", 0},
+		{fmt.Sprintf("_page=%d&_limit=%d", (n+2)/3, 3), (n-1)%3 + 1}, // the last, maybe short
+		{fmt.Sprintf("_page=%d&_limit=%d", (n+2)/3+1, 3), 0},
+		{"_page=4611686018427387905&_limit=3", 0},
+		{"_page=2&_limit=9223372036854775807", 0},
+		{"_page=9223372036854775807&_limit=9223372036854775807", 0},
+		{"_page=1&_limit=9223372036854775807", n},
+		{"_page=-5&_limit=3", 3},
+		{"_page=1&_limit=-3", 10},
+		{"_page=99999999999999999999&_limit=3", 3}, // not a number, so page 1
+		{"_start=9223372036854775807&_end=9223372036854775807", 0},
+		{"_start=-1&_end=2", 2},
+	} {
+		rec := httptest.NewRecorder()
+		f.ServeHTTP(rec, httptest.NewRequest("GET", "/todos?"+c.query, nil))
+
+		var todos []jsonplaceholder.Todo
+		if err := json.Unmarshal(rec.Body.Bytes(), &todos); err != nil || rec.Code != http.StatusOK {
+			t.Errorf("%s: %d %v", c.query, rec.Code, err)
+			continue
+		}
+
+		if len(todos) != c.want {
+			t.Errorf("%s: got %d todos, want %d", c.query, len(todos), c.want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module jsonplaceholder
+
+go 1.22.3
@@ -35,11 +35,6 @@ func main() {
 
 	fn := os.Args[1]
 
-	if input, err = os.Open(fn); err != nil {
-		fmt.Fprintf(os.Stderr, err)
-		os.Exit(-1)
-	}
-
 	var (
 		input io.ReadCloser
 		items []xkcd
@@ -48,6 +43,22 @@ func main() {
 		err   error
 	)
 
+	if input, err = os.Open(fn); err != nil {
+		fmt.Fprintf(os.Stderr, "bad file: %s\n", err)
+		os.Exit(-1)
+	}
+
+	defer input.Close()
+
+	// decode the whole file in one go
+
+	if err = json.NewDecoder(input).Decode(&items); err != nil {
+		fmt.Fprintf(os.Stderr, "bad json: %s\n", err)
+		os.Exit(-1)
+	}
+
+	fmt.Fprintf(os.Stderr, "read %d comics\n", len(items))
+
 	for _, t := range os.Args[2:] {
 		terms = append(terms, strings.ToLower(t))
 	}
@@ -57,7 +68,22 @@ func main() {
 		os.Exit(-1)
 	}
 
-	// some code here
+	// a comic matches if every term is in its title or transcript
+
+outer:
+	for _, item := range items {
+		title := strings.ToLower(item.Title)
+		transcript := strings.ToLower(item.Transcript)
+
+		for _, term := range terms {
+			if !strings.Contains(title, term) && !strings.Contains(transcript, term) {
+				continue outer
+			}
+		}
+
+		fmt.Printf("https://xkcd.com/%d/ %s/%s/%s  %q\n", item.Num, item.Month, item.Day, item.Year, item.Title)
+		cnt++
+	}
 
 	fmt.Fprintf(os.Stderr, "found %d comics\n", cnt)
 }
@@ -49,7 +49,7 @@ func main() {
 		data   []byte
 	)
 
-	if len(os.Args) > 0 {
+	if len(os.Args) > 1 {
 		output, err = os.Create(os.Args[1])
 
 		if err != nil {
@@ -66,7 +66,29 @@ func main() {
 	fmt.Fprint(output, "[")
 	defer fmt.Fprint(output, "]")
 
-	// some code here
+	// there's no comic #404, so it takes two misses in a row to
+	// know we've read them all
+
+	for i := 1; fails < 2; i++ {
+		if data = getOne(i); data == nil {
+			fails++
+			continue
+		}
+
+		if cnt > 0 {
+			fmt.Fprint(output, ",") // OB1
+		}
+
+		_, err = io.Copy(output, bytes.NewBuffer(data))
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "stopped: %s\n", err)
+			os.Exit(-1)
+		}
+
+		fails = 0
+		cnt++
+	}
 
 	fmt.Fprintf(os.Stderr, "read %d comics\n", cnt)
 }
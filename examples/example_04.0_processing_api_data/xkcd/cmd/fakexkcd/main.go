@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"xkcd/fake"
+)
+
+// Runs the fake xkcd.com on its own, so xkcd load can work offline, e.g.
+//
+//	go run ./cmd/fakexkcd -addr :8082 -comics 500 -latency 50ms
+//	go run ./cmd/xkcd load -base http://localhost:8082 -data /tmp/xkcd.json
+
+func main() {
+	addr := flag.String("addr", ":8082", "address to listen on")
+	comics := flag.Int("comics", 100, "number of comics served")
+	latency := flag.Duration("latency", 0, "delay added to every request")
+	flag.Parse()
+
+	srv := fake.New(fake.Comics(*comics))
+	srv.SetLatency(*latency)
+
+	log.Printf("Fake xkcd with %d comics listening on %s", *comics, *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
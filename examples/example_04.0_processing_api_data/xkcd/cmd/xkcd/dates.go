@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// period reads a year, a month or a day, as 2010, 2010-04 or 2010-04-20,
+// and returns the first day of it and the first day after it.
+func period(s string) (start, end time.Time, err error) {
+	for _, p := range []struct {
+		layout string
+		next   func(time.Time) time.Time
+	}{
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+		{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	} {
+		if t, err := time.Parse(p.layout, s); err == nil {
+			return t, p.next(t), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("bad date %q, want 2010, 2010-04 or 2010-04-20", s)
+}
+
+// dateRange turns -since and -until into the limits index.Within takes.
+// Both include the whole period they name, so -until 2010 means up to the
+// end of 2010. An empty one is no limit.
+func dateRange(since, until string) (from, to time.Time, err error) {
+	if since != "" {
+		if from, _, err = period(since); err != nil {
+			return
+		}
+	}
+
+	if until != "" {
+		if _, to, err = period(until); err != nil {
+			return
+		}
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		err = fmt.Errorf("%s is after %s", since, until)
+	}
+	return
+}
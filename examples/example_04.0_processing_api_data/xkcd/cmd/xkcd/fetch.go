@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"xkcd"
+)
+
+// errNoComic is what fetching a comic that doesn't exist returns. There's
+// no comic 404, xkcd answers it with a 404.
+var errNoComic = errors.New("no such comic")
+
+// transientError is a failure worth trying again: a network error, a 5xx or
+// a 429 from the server.
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string { return e.err.Error() }
+func (e transientError) Unwrap() error { return e.err }
+
+// maxBackoff is the longest a retry waits, whatever loader.backoff and
+// the number of retries.
+const maxBackoff = time.Minute
+
+// loader fetches comic descriptions, a bounded number at a time and no
+// faster than its limiter allows.
+type loader struct {
+	base    string
+	client  *http.Client
+	limiter *tokenBucket
+	workers int           // comics fetched at the same time
+	timeout time.Duration // per attempt
+	retries int           // attempts per comic after the first
+	backoff time.Duration // before the first retry, doubled for every other one
+
+	sleep func(context.Context, time.Duration) error // replaced in tests
+}
+
+// newLoader returns a loader for the API at baseURL, https://xkcd.com or a
+// stand-in such as the fake package, with the same defaults as load.
+func newLoader(baseURL string, client *http.Client) *loader {
+	return &loader{
+		base:    baseURL,
+		client:  client,
+		limiter: newTokenBucket(10, 5),
+		workers: 8,
+		timeout: 10 * time.Second,
+		retries: 3,
+		backoff: 500 * time.Millisecond,
+		sleep:   sleep,
+	}
+}
+
+// get fetches comic num, or the latest one for 0, retrying transient failures.
+func (l *loader) get(ctx context.Context, num int) (xkcd.ComicDescription, error) {
+	targetURL := l.base + comicDescriptionEndpoint
+	if num > 0 {
+		targetURL = fmt.Sprintf("%s/%d%s", l.base, num, comicDescriptionEndpoint)
+	}
+
+	var comic xkcd.ComicDescription
+	err := l.retry(ctx, func() (err error) {
+		comic, err = l.getOnce(ctx, targetURL)
+		return err
+	})
+	return comic, err
+}
+
+// retry calls attempt until it succeeds, fails for good, or has been
+// retried l.retries times, sleeping longer and longer in between.
+func (l *loader) retry(ctx context.Context, attempt func() error) error {
+	for n := 0; ; n++ {
+		err := attempt()
+
+		var transient transientError
+		if err == nil || !errors.As(err, &transient) || n >= l.retries || ctx.Err() != nil {
+			return err
+		}
+
+		// full jitter, so the workers don't all come back at the same moment;
+		// the cap keeps the shift from overflowing with many retries
+		delay := time.Duration(rand.Int63n(int64(min(l.backoff<<min(n, 10), maxBackoff)) + 1))
+		if err := l.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *loader) getOnce(ctx context.Context, targetURL string) (xkcd.ComicDescription, error) {
+	if err := l.limiter.Wait(ctx); err != nil {
+		return xkcd.ComicDescription{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return xkcd.ComicDescription{}, err
+	}
+
+	content, err := l.client.Do(req)
+	if err != nil {
+		return xkcd.ComicDescription{}, transientError{err}
+	}
+
+	defer content.Body.Close()
+
+	switch {
+	case content.StatusCode == http.StatusNotFound:
+		return xkcd.ComicDescription{}, errNoComic
+
+	case content.StatusCode == http.StatusTooManyRequests || content.StatusCode >= 500:
+		return xkcd.ComicDescription{}, transientError{fmt.Errorf("%s: %s", targetURL, content.Status)}
+
+	case content.StatusCode != http.StatusOK:
+		return xkcd.ComicDescription{}, fmt.Errorf("%s: %s", targetURL, content.Status)
+	}
+
+	var comic xkcd.ComicDescription
+	err = json.NewDecoder(content.Body).Decode(&comic)
+	if err != nil {
+		return xkcd.ComicDescription{}, fmt.Errorf("%s: %w", targetURL, err)
+	}
+
+	// asking again won't fix the date
+	if err := comic.SetPublished(); err != nil {
+		return xkcd.ComicDescription{}, fmt.Errorf("%s: %w", targetURL, err)
+	}
+	return comic, nil
+}
+
+// fetchAll fetches the comics numbered nums with l.workers goroutines. It
+// returns the comics it got, and an error for each other one except those
+// that don't exist. Once ctx is done the comics not started yet are skipped.
+func (l *loader) fetchAll(ctx context.Context, nums []int) ([]xkcd.ComicDescription, []error) {
+	jobs := make(chan int)
+	comicChannel := make(chan ComicResult)
+	var wg sync.WaitGroup
+
+	for w := 0; w < max(l.workers, 1); w++ {
+		wg.Add(1) // Increment the WaitGroup counter
+		go func() {
+			defer wg.Done()
+			for comicNumber := range jobs {
+				comic, err := l.get(ctx, comicNumber)
+				if err != nil {
+					err = fmt.Errorf("error fetching comic %d: %w", comicNumber, err)
+				}
+				comicChannel <- ComicResult{Comic: comic, Error: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, num := range nums {
+			select {
+			case jobs <- num:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()           // Wait for all workers to finish, the counter is decremented by each call to wg.Done()
+		close(comicChannel) // Close the channel when all workers are done
+	}()
+
+	var (
+		comics []xkcd.ComicDescription
+		errs   []error
+	)
+
+	for result := range comicChannel {
+		switch {
+		case result.Error == nil:
+			comics = append(comics, result.Comic)
+		case errors.Is(result.Error, errNoComic):
+			// nothing to fetch, and nothing to complain about
+		default:
+			errs = append(errs, result.Error)
+		}
+	}
+
+	return comics, errs
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"xkcd"
+	"xkcd/comicstore"
+	"xkcd/fake"
+)
+
+func testLoader(t *testing.T, comics []xkcd.ComicDescription) (*fake.Server, *loader) {
+	t.Helper()
+
+	f := fake.New(comics)
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	l := newLoader(srv.URL, srv.Client())
+	l.limiter = newTokenBucket(1000, 100)
+	l.sleep = func(context.Context, time.Duration) error { return nil }
+
+	return f, l
+}
+
+func nums(from, to int) []int {
+	var nums []int
+	for i := from; i <= to; i++ {
+		nums = append(nums, i)
+	}
+	return nums
+}
+
+func TestFetchAll(t *testing.T) {
+	f, l := testLoader(t, fake.Comics(30))
+	f.SetLatency(10 * time.Millisecond)
+	l.workers = 4
+
+	// 31 doesn't exist, which isn't an error
+	comics, errs := l.fetchAll(context.Background(), nums(1, 31))
+
+	if len(comics) != 30 || len(errs) != 0 {
+		t.Fatalf("got %d comics and %v", len(comics), errs)
+	}
+
+	for _, comic := range comics {
+		if comic.Published.IsZero() {
+			t.Errorf("comic %d has no date", comic.Num)
+		}
+	}
+
+	if peak := f.Peak(); peak < 2 || peak > 4 {
+		t.Errorf("%d requests at once with 4 workers", peak)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	comics := fake.Comics(10)
+	comics[7].Month = "13" // comic 8
+
+	f, l := testLoader(t, comics)
+	l.timeout = 50 * time.Millisecond
+
+	f.Fail(3, fake.Fault{Status: http.StatusServiceUnavailable, Times: 2})
+	f.Fail(4, fake.Fault{Status: http.StatusInternalServerError})
+	f.Fail(5, fake.Fault{Malformed: true})
+	f.Fail(6, fake.Fault{Delay: time.Second, Times: 1})
+	f.Fail(7, fake.Fault{Status: http.StatusForbidden})
+
+	got, errs := l.fetchAll(context.Background(), nums(1, 10))
+
+	var fetched []int
+	for _, comic := range got {
+		fetched = append(fetched, comic.Num)
+	}
+	slices.Sort(fetched)
+
+	if want := []int{1, 2, 3, 6, 9, 10}; !slices.Equal(fetched, want) {
+		t.Errorf("fetched %v, want %v", fetched, want)
+	}
+
+	var failed []string
+	for _, err := range errs {
+		failed = append(failed, strings.Fields(err.Error())[3]) // error fetching comic N: ...
+	}
+	slices.Sort(failed)
+
+	if want := []string{"4:", "5:", "7:", "8:"}; !slices.Equal(failed, want) {
+		t.Errorf("failed %v, want %v: %v", failed, want, errs)
+	}
+
+	// only network errors, timeouts and 5xx are tried again
+	for num, want := range map[int]int{3: 3, 4: 1 + l.retries, 5: 1, 6: 2, 7: 1, 8: 1} {
+		if got := f.Requests(num); got != want {
+			t.Errorf("comic %d asked for %d times, want %d", num, got, want)
+		}
+	}
+}
+
+func TestFetchCanceled(t *testing.T) {
+	f, l := testLoader(t, fake.Comics(100))
+	f.SetLatency(20 * time.Millisecond)
+	l.workers = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	comics, errs := l.fetchAll(ctx, nums(1, 100))
+
+	if len(comics) == 0 || len(comics) >= 100 {
+		t.Errorf("got %d comics before the deadline", len(comics))
+	}
+
+	for _, err := range errs {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	f, l := testLoader(t, fake.Comics(20))
+	path := filepath.Join(t.TempDir(), "xkcd.ndjson")
+
+	args := []string{"-base", l.base, "-data", path, "-rate", "1000"}
+
+	if err := load(args); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second time there's nothing to fetch but the latest
+	if err := load(args); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Requests(0) != 2 || f.Requests(20) != 1 {
+		t.Errorf("asked for the latest %d times and comic 20 %d times", f.Requests(0), f.Requests(20))
+	}
+
+	store, err := comicstore.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if got := store.Nums(); !slices.Equal(got, nums(1, 20)) {
+		t.Errorf("stored %v", got)
+	}
+}
+
+func TestMissingComics(t *testing.T) {
+	for _, test := range []struct {
+		stored []int
+		latest int
+		want   []int
+	}{
+		{nil, 3, []int{1, 2, 3}},
+		{[]int{1, 2, 5}, 7, []int{3, 4, 6, 7}},
+		{[]int{1, 2, 3}, 3, nil},
+		{nums(1, 403), 405, []int{405}}, // never 404
+	} {
+		if got := missingComics(test.stored, test.latest); !slices.Equal(got, test.want) {
+			t.Errorf("missingComics(%v, %d) = %v, want %v", test.stored, test.latest, got, test.want)
+		}
+	}
+}
+
+// A comic that failed is fetched on the next run, and only that one.
+func TestLoadResumes(t *testing.T) {
+	f, l := testLoader(t, fake.Comics(10))
+	f.Fail(5, fake.Fault{Status: http.StatusInternalServerError})
+
+	path := filepath.Join(t.TempDir(), "xkcd.ndjson")
+	args := []string{"-base", l.base, "-data", path, "-rate", "1000", "-retries", "0"}
+
+	if err := load(args); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Fail(5, fake.Fault{})
+
+	if err := load(args); err != nil {
+		t.Fatal(err)
+	}
+
+	for num, want := range map[int]int{4: 1, 5: 2, 6: 1} {
+		if got := f.Requests(num); got != want {
+			t.Errorf("comic %d asked for %d times, want %d", num, got, want)
+		}
+	}
+
+	store, err := comicstore.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if got := store.Nums(); !slices.Equal(got, nums(1, 10)) {
+		t.Errorf("stored %v", got)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	l := newLoader("", nil)
+	l.retries, l.backoff = 3, 100*time.Millisecond
+
+	var delays []time.Duration
+	l.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	attempts := 0
+	transient := func() error {
+		attempts++
+		return transientError{errors.New("503")}
+	}
+
+	if err := l.retry(context.Background(), transient); err == nil || attempts != 4 {
+		t.Errorf("got %v after %d attempts", err, attempts)
+	}
+
+	// full jitter: anything up to the doubled backoff
+	for n, d := range delays {
+		if d < 0 || d > l.backoff<<n {
+			t.Errorf("retry %d after %s", n+1, d)
+		}
+	}
+
+	// however many retries, the delay neither overflows nor exceeds the cap
+	l.retries, attempts, delays = 100, 0, nil
+
+	if err := l.retry(context.Background(), transient); err == nil || attempts != 101 {
+		t.Errorf("got %v after %d attempts", err, attempts)
+	}
+
+	for n, d := range delays {
+		if d < 0 || d > maxBackoff {
+			t.Fatalf("retry %d after %s", n+1, d)
+		}
+	}
+
+	l.retries, attempts = 3, 0
+	errs := []error{transientError{errors.New("503")}, nil}
+
+	if err := l.retry(context.Background(), func() error { attempts++; return errs[attempts-1] }); err != nil || attempts != 2 {
+		t.Errorf("got %v after %d attempts, want success after 2", err, attempts)
+	}
+
+	attempts = 0
+
+	if err := l.retry(context.Background(), func() error { attempts++; return errNoComic }); !errors.Is(err, errNoComic) || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want no retry", err, attempts)
+	}
+
+	attempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := l.retry(ctx, transient); err == nil || attempts != 1 {
+		t.Errorf("got %v after %d attempts once canceled", err, attempts)
+	}
+}
+
+func TestLoadFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-rate", "0"},
+		{"-rate", "-1"},
+		{"-burst", "0"},
+	} {
+		// checked before anything is fetched, so there's no need for a server
+		if err := load(append(args, "-base", "http://127.0.0.1:1")); err == nil || !strings.Contains(err.Error(), args[0]) {
+			t.Errorf("load %v: %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(10, 3)
+
+	// a burst right away, then a token every 100ms
+	for i := range 3 {
+		if d := b.take(); d != 0 {
+			t.Fatalf("request %d of the burst waits %s", i+1, d)
+		}
+	}
+
+	if d := b.take(); d <= 0 || d > 100*time.Millisecond {
+		t.Errorf("after the burst: wait %s", d)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	b := newTokenBucket(100, 1)
+	start := time.Now()
+
+	for range 5 {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// one at once, then four at 10ms apart
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("5 requests at 100/s in %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	slow := newTokenBucket(0.1, 1)
+	slow.take()
+
+	if err := slow.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("empty bucket: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"xkcd"
+	"xkcd/comicstore"
+)
+
+var comicDescriptionEndpoint = "/info.0.json"
+
+type ComicResult struct {
+	Comic xkcd.ComicDescription
+	Error error
+}
+
+// There's no comic 404; we don't even ask for it
+var comicsThatDontExist = map[int]bool{404: true}
+
+// load fetches the comics missing from the store, and their images with
+// -mirror.
+func load(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	data := dataFlag(fs)
+	baseURL := fs.String("base", "https://xkcd.com", "xkcd base URL, or a stand-in such as cmd/fakexkcd")
+	workers := fs.Int("workers", 8, "comics fetched at the same time")
+	rate := fs.Float64("rate", 10, "requests per second at most")
+	burst := fs.Int("burst", 5, "requests allowed at once after a quiet spell")
+	timeout := fs.Duration("timeout", 10*time.Second, "time limit per request")
+	retries := fs.Int("retries", 3, "retries per comic for network errors and 5xx responses")
+	mirror := fs.String("mirror", "", "also download the comic images into this directory (like ../images)")
+	fs.Parse(args)
+
+	// a rate of 0 would never refill the bucket, a burst of 0 never hold a token
+	if *rate <= 0 {
+		return fmt.Errorf("-rate %g: want more than 0 requests per second", *rate)
+	}
+
+	if *burst < 1 {
+		return fmt.Errorf("-burst %d: want at least 1", *burst)
+	}
+
+	// On Ctrl-C we stop asking for more and still save what we've got;
+	// the next run picks up from there
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	l := newLoader(*baseURL, http.DefaultClient)
+	l.limiter = newTokenBucket(*rate, *burst)
+	l.workers, l.timeout, l.retries = *workers, *timeout, *retries
+
+	// Open picks the format from the name: xkcd.ndjson is an append-only
+	// log, anything else one JSON array rewritten on every change
+	store, err := comicstore.Open(*data)
+	if err != nil {
+		return fmt.Errorf("opening comics: %w", err)
+	}
+	defer store.Close()
+
+	// The current comic tells us how many there are
+	latest, err := l.get(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("fetching the latest comic: %w", err)
+	}
+
+	stored := store.Nums()
+	missing := missingComics(stored, latest.Num)
+	fmt.Printf("%d comics stored, latest is %d, fetching %d\n", len(stored), latest.Num, len(missing))
+
+	fetched, errs := l.fetchAll(ctx, missing)
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted, saving what we have")
+	}
+
+	if len(fetched) > 0 {
+		// the workers finish in any order; the log reads better in order
+		sort.Slice(fetched, func(i, j int) bool {
+			return fetched[i].Num < fetched[j].Num
+		})
+
+		if err := store.Put(fetched...); err != nil {
+			return fmt.Errorf("writing comics: %w", err)
+		}
+		fmt.Printf("%d comics written to %s, %d failed\n", len(fetched), *data, len(errs))
+	}
+
+	if *mirror == "" || ctx.Err() != nil {
+		return nil
+	}
+
+	comics, err := store.All()
+	if err != nil {
+		return fmt.Errorf("reading comics: %w", err)
+	}
+
+	updated, mirrorErrs := l.mirrorAll(ctx, comics, *mirror)
+	for _, err := range mirrorErrs {
+		fmt.Println(err)
+	}
+	fmt.Printf("%d images mirrored to %s, %d failed\n", len(updated), *mirror, len(mirrorErrs))
+
+	if len(updated) == 0 {
+		return nil
+	}
+
+	if err := store.Put(updated...); err != nil {
+		return fmt.Errorf("writing comics: %w", err)
+	}
+
+	// the log now has two versions of each of those comics
+	if c, ok := store.(comicstore.Compacter); ok {
+		if err := c.Compact(); err != nil {
+			return fmt.Errorf("compacting comics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// missingComics lists the numbers from 1 to latest we have no comic for,
+// leaving out the ones that were never published.
+func missingComics(stored []int, latest int) []int {
+	have := make(map[int]bool, len(stored))
+	for _, num := range stored {
+		have[num] = true
+	}
+
+	var missing []int
+	for i := 1; i <= latest; i++ {
+		if !have[i] && !comicsThatDontExist[i] {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
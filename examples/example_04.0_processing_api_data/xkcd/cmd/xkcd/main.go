@@ -0,0 +1,80 @@
+// Command xkcd downloads the descriptions of the xkcd comics and works with
+// them offline:
+//
+//	xkcd load              fetch the comics that are missing, and their images
+//	xkcd search query      search them, or serve a search page with -http
+//	xkcd show 571          print one
+//	xkcd random            print any one
+//	xkcd stats             count them by year, and their transcripts
+//
+// Every subcommand takes -data, the file the comics are kept in.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"xkcd/comicstore"
+)
+
+type command struct {
+	name string
+	args string
+	help string
+	run  func(args []string) error
+}
+
+var commands = []command{
+	{"load", "[flags]", "fetch the comics missing from -data, and their images with -mirror", load},
+	{"search", "[flags] query", "search the comics, or serve the search page with -http", search},
+	{"show", "[flags] num", "print comic num", show},
+	{"random", "[flags]", "print a comic picked at random", random},
+	{"stats", "[flags]", "count the comics by year, and the ones without a transcript", stats},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+
+		if err := c.run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "xkcd %s: %s\n", c.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: xkcd command [flags] [args]\n\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-7s %-14s %s\n", c.name, c.args, c.help)
+	}
+	fmt.Fprintf(os.Stderr, "\nxkcd command -h shows the flags of a command.\n")
+}
+
+// dataFlag adds -data, the file with the comics, to a subcommand.
+func dataFlag(fs *flag.FlagSet) *string {
+	return fs.String("data", "../xkcd.json", "file with the comics: a .json array, or an .ndjson log")
+}
+
+// openComics opens the comics load wrote. comicstore.Open would create the
+// file, but reading from an empty store is always a mistake.
+func openComics(path string) (comicstore.ComicStore, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s doesn't exist, run xkcd load first", path)
+	}
+	return comicstore.Open(path)
+}
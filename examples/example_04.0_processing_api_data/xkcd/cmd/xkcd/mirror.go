@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // the image formats xkcd uses, for the dimensions
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"xkcd"
+)
+
+// mirrored says if comic's image is already in dir.
+func mirrored(comic xkcd.ComicDescription, dir string) bool {
+	if comic.Image == nil {
+		return false
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(comic.Image.Path)))
+	return err == nil && fi.Size() == comic.Image.Size
+}
+
+// mirrorAll downloads the images of the comics that aren't in dir yet, with
+// l.workers goroutines. It returns the comics it downloaded images for,
+// with their Image set, and an error for each it couldn't.
+func (l *loader) mirrorAll(ctx context.Context, comics []xkcd.ComicDescription, dir string) ([]xkcd.ComicDescription, []error) {
+	jobs := make(chan xkcd.ComicDescription)
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		updated []xkcd.ComicDescription
+		errs    []error
+	)
+
+	for w := 0; w < max(l.workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for comic := range jobs {
+				info, err := l.mirror(ctx, comic.Img, dir)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("error mirroring comic %d: %w", comic.Num, err))
+				} else {
+					comic.Image = info
+					updated = append(updated, comic)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, comic := range comics {
+		// a few interactive comics have no image at all
+		if mirrored(comic, dir) || path.Base(comic.Img) == "comics" || comic.Img == "" {
+			continue
+		}
+
+		select {
+		case jobs <- comic:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return updated, errs
+}
+
+// mirror downloads the image at targetURL into dir, retrying transient
+// failures.
+func (l *loader) mirror(ctx context.Context, targetURL, dir string) (*xkcd.ImageInfo, error) {
+	var info *xkcd.ImageInfo
+	err := l.retry(ctx, func() (err error) {
+		info, err = l.mirrorOnce(ctx, targetURL, dir)
+		return err
+	})
+	return info, err
+}
+
+func (l *loader) mirrorOnce(ctx context.Context, targetURL, dir string) (*xkcd.ImageInfo, error) {
+	if err := l.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := l.client.Do(req)
+	if err != nil {
+		return nil, transientError{err}
+	}
+
+	defer content.Body.Close()
+
+	switch {
+	case content.StatusCode == http.StatusTooManyRequests || content.StatusCode >= 500:
+		return nil, transientError{fmt.Errorf("%s: %s", targetURL, content.Status)}
+
+	case content.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", targetURL, content.Status)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// We only know the name once we've seen all of it
+	tmp, err := os.CreateTemp(dir, "download-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// an image that's kept has been renamed into its directory by then
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(tmp, hash), content.Body)
+	if err != nil {
+		return nil, transientError{err}
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	config, format, err := image.DecodeConfig(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", targetURL, err)
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	info := &xkcd.ImageInfo{
+		Path:   sum[:2] + "/" + sum + "." + format,
+		SHA256: sum,
+		Size:   size,
+		Width:  config.Width,
+		Height: config.Height,
+	}
+
+	if err := tmp.Chmod(0o644); err != nil {
+		return nil, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	final := filepath.Join(dir, filepath.FromSlash(info.Path))
+
+	if err := os.MkdirAll(filepath.Dir(final), 0o755); err != nil {
+		return nil, err
+	}
+
+	return info, os.Rename(tmp.Name(), final)
+}
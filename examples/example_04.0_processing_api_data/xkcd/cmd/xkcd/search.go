@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"xkcd"
+	"xkcd/index"
+)
+
+// search runs one query, or serves the search page and API with -http.
+func search(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	data := dataFlag(fs)
+	indexPath := fs.String("index", "", "index file, built when missing or older than the comics (default next to -data)")
+	rank := fs.String("rank", "bm25", "ranking: bm25 or tfidf")
+	limit := fs.Int("n", 20, "number of results shown, 0 for all")
+	reindex := fs.Bool("reindex", false, "build the index again even if it's up to date")
+	width := fs.Int("snippet", 80, "length of the snippets shown with the results, 0 for none")
+	addr := fs.String("http", "", "serve the search page and API on this address (like :8080) instead")
+	images := fs.String("images", "", "with -http, serve the images from this mirror (see load -mirror) instead of xkcd.com")
+	since := fs.String("since", "", "only comics published since this year, month or day (2010, 2010-04 or 2010-04-20)")
+	until := fs.String("until", "", "only comics published until the end of this year, month or day")
+	order := fs.String("sort", "score", "order of the results: score, oldest or newest")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: xkcd search [flags] query\n")
+		fmt.Fprintf(fs.Output(), "       xkcd search [flags] -http :8080\n\n")
+		fmt.Fprintf(fs.Output(), "  query: words, \"phrases\", AND, OR, NOT, -word and (parentheses)\n")
+		fmt.Fprintf(fs.Output(), "         title:, alt: or transcript: for one field, word~ or word~2 for typos,\n")
+		fmt.Fprintf(fs.Output(), "         year:2010, num:100..200, year:..2008 for ranges\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ranking, err := index.ParseRanking(*rank)
+	if err != nil {
+		return err
+	}
+
+	sortBy, err := index.ParseOrder(*order)
+	if err != nil {
+		return err
+	}
+
+	from, to, err := dateRange(*since, *until)
+	if err != nil {
+		return err
+	}
+
+	if *indexPath == "" {
+		// the whole name, so xkcd.json and xkcd.ndjson don't share one
+		*indexPath = *data + ".index"
+	}
+
+	if *reindex {
+		os.Remove(*indexPath)
+	}
+
+	// The snippets need the text, which the index doesn't keep
+	comics, err := openComics(*data)
+	if err != nil {
+		return err
+	}
+	defer comics.Close()
+
+	ix, err := index.LoadOrBuild(*indexPath, *data, func() ([]index.Document, error) {
+		all, err := comics.All()
+		if err != nil {
+			return nil, err
+		}
+
+		docs := make([]index.Document, len(all))
+		for i, comic := range all {
+			docs[i] = index.Document{Num: comic.Num, Published: comic.Published, Title: comic.Title, Alt: comic.Alt, Transcript: comic.Transcript}
+		}
+		return docs, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if *addr != "" {
+		srv := &http.Server{
+			Addr:              *addr,
+			Handler:           newServer(comics, ix, ranking, *images).routes(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		fmt.Printf("Serving %d comics on %s\n", len(comics.Nums()), *addr)
+		return srv.ListenAndServe()
+	}
+
+	query := strings.Join(fs.Args(), " ")
+
+	results, err := ix.Search(query, ranking)
+	if err != nil {
+		return err
+	}
+
+	results = index.Within(results, from, to)
+	index.Sort(results, sortBy)
+
+	fmt.Printf("Query: %s\n", query)
+	fmt.Printf("Matched Comics (%d, by %s):\n", len(results), orderName(sortBy, ranking))
+	for i, result := range results {
+		if *limit > 0 && i == *limit {
+			fmt.Printf("    ... and %d more\n", len(results)-i)
+			break
+		}
+		fmt.Printf("    %4d: %50s  %10s  %6.2f\t[ https://xkcd.com/%[1]d ]\n", result.Num, result.Title, day(result.Published), result.Score)
+
+		if *width > 0 {
+			// only the comics shown are read, not all of them
+			comic, err := comics.Get(result.Num)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+
+			if snippet := snippetOf(comic, result.Terms, *width, highlight); snippet != "" {
+				fmt.Printf("          %s\n", snippet)
+			}
+		}
+	}
+
+	return nil
+}
+
+// orderName says how the results are sorted: by the ranking, or by date.
+func orderName(order index.Order, ranking index.Ranking) string {
+	if order == index.ByScore {
+		return ranking.String()
+	}
+	return order.String() + " first"
+}
+
+// snippetOf shows where the comic matched: in the transcript if it did,
+// else in the alt text or the title.
+func snippetOf(comic xkcd.ComicDescription, terms []string, width int, mark func(string) string) string {
+	for _, text := range []string{comic.Transcript, comic.Alt, comic.Title} {
+		if snippet := index.Snippet(text, terms, width, mark); snippet != "" {
+			return snippet
+		}
+	}
+	return ""
+}
+
+// highlight makes matched words bold on a terminal and *starred* elsewhere.
+func highlight(word string) string {
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return "\x1b[1m" + word + "\x1b[0m"
+	}
+	return "*" + word + "*"
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"xkcd"
+	"xkcd/comicstore"
+	"xkcd/index"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+	snippetWidth    = 160
+)
+
+//go:embed search.html
+var searchPage string
+
+var searchTemplate = template.Must(template.New("search").Parse(searchPage))
+
+// server answers searches from an index loaded once, for everybody.
+type server struct {
+	ix      *index.Index
+	comics  comicstore.ComicStore
+	ranking index.Ranking
+	images  string // directory of mirrored images, "" to link to xkcd.com
+}
+
+func newServer(comics comicstore.ComicStore, ix *index.Index, ranking index.Ranking, images string) *server {
+	return &server{ix: ix, comics: comics, ranking: ranking, images: images}
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /search", s.search)
+	mux.HandleFunc("GET /comic/{num}", s.comic)
+	mux.HandleFunc("GET /{$}", s.page)
+
+	if s.images != "" {
+		// the files are named after their checksum and never change
+		files := http.StripPrefix("/images/", http.FileServer(http.Dir(s.images)))
+		mux.Handle("GET /images/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			files.ServeHTTP(w, r)
+		}))
+	}
+
+	return mux
+}
+
+type hit struct {
+	Num       int           `json:"num"`
+	Title     string        `json:"title"`
+	Published string        `json:"published,omitempty"` // 2009-04-20
+	Score     float64       `json:"score"`
+	Snippet   template.HTML `json:"snippet,omitempty"` // escaped, with <mark>ed matches
+	Img       string        `json:"img"`               // the mirrored copy if there's one
+	Width     int           `json:"width,omitempty"`
+	Height    int           `json:"height,omitempty"`
+	URL       string        `json:"url"`
+}
+
+type resultPage struct {
+	Query   string `json:"query"`
+	Total   int    `json:"total"`
+	Page    int    `json:"page"`
+	Size    int    `json:"size"`
+	Pages   int    `json:"pages"`
+	Results []hit  `json:"results"`
+	Prev    string `json:"prev,omitempty"`
+	Next    string `json:"next,omitempty"`
+}
+
+// search serves /search?q=...&page=2&size=10&rank=tfidf&sort=newest&since=2010&until=2012-06
+// as JSON.
+func (s *server) search(w http.ResponseWriter, r *http.Request) {
+	page, err := s.run(r.URL)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// comic serves /comic/{num}, the description as load stored it.
+func (s *server) comic(w http.ResponseWriter, r *http.Request) {
+	num, err := strconv.Atoi(r.PathValue("num"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such comic"})
+		return
+	}
+
+	comic, err := s.comics.Get(num)
+	switch {
+	case errors.Is(err, comicstore.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such comic"})
+		return
+
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comic)
+}
+
+// page is the search form, with the results of q if there's one.
+func (s *server) page(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		Query string
+		Since string
+		Until string
+		Sort  string
+		Error string
+		Page  *resultPage
+	}
+
+	status := http.StatusOK
+	q := r.URL.Query()
+	data.Since, data.Until, data.Sort = q.Get("since"), q.Get("until"), q.Get("sort")
+
+	if data.Query = q.Get("q"); data.Query != "" {
+		page, err := s.run(r.URL)
+		if err != nil {
+			data.Error = err.Error()
+			status = http.StatusBadRequest
+		}
+		data.Page = page
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	searchTemplate.Execute(w, data)
+}
+
+// run reads the search parameters of u and returns that page of results.
+func (s *server) run(u *url.URL) (*resultPage, error) {
+	q := u.Query()
+
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		return nil, errors.New("missing q")
+	}
+
+	page, err := intParam(q, "page", 1, 1, 1<<20)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := intParam(q, "size", defaultPageSize, 1, maxPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ranking := s.ranking
+	if v := q.Get("rank"); v != "" {
+		if ranking, err = index.ParseRanking(v); err != nil {
+			return nil, err
+		}
+	}
+
+	order := index.ByScore
+	if v := q.Get("sort"); v != "" {
+		if order, err = index.ParseOrder(v); err != nil {
+			return nil, err
+		}
+	}
+
+	since, until, err := dateRange(q.Get("since"), q.Get("until"))
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.ix.Search(query, ranking)
+	if err != nil {
+		return nil, err
+	}
+
+	results = index.Within(results, since, until)
+	index.Sort(results, order)
+
+	rp := &resultPage{
+		Query:   query,
+		Total:   len(results),
+		Page:    page,
+		Size:    size,
+		Pages:   (len(results) + size - 1) / size,
+		Results: []hit{},
+	}
+
+	from := min((page-1)*size, len(results))
+	to := min(from+size, len(results))
+
+	for _, result := range results[from:to] {
+		// one the store can't read just goes without a snippet or image
+		comic, _ := s.comics.Get(result.Num)
+
+		h := hit{
+			Num:     result.Num,
+			Title:   result.Title,
+			Score:   result.Score,
+			Snippet: htmlSnippet(comic, result.Terms),
+			Img:     comic.Img,
+			URL:     "https://xkcd.com/" + strconv.Itoa(result.Num) + "/",
+		}
+
+		if !result.Published.IsZero() {
+			h.Published = result.Published.Format(time.DateOnly)
+		}
+
+		if s.images != "" && comic.Image != nil {
+			h.Img = "/images/" + comic.Image.Path
+			h.Width, h.Height = comic.Image.Width, comic.Image.Height
+		}
+
+		rp.Results = append(rp.Results, h)
+	}
+
+	if page > 1 {
+		rp.Prev = pageLink(u, page-1)
+	}
+
+	if page < rp.Pages {
+		rp.Next = pageLink(u, page+1)
+	}
+
+	return rp, nil
+}
+
+// htmlSnippet is snippetOf as safe HTML. The matches are marked with
+// control characters no comic has, then escaped along with the rest and
+// only then turned into tags.
+func htmlSnippet(comic xkcd.ComicDescription, terms []string) template.HTML {
+	marked := snippetOf(comic, terms, snippetWidth, func(word string) string { return "\x00" + word + "\x01" })
+
+	escaped := html.EscapeString(marked)
+	escaped = strings.NewReplacer("\x00", "<mark>", "\x01", "</mark>").Replace(escaped)
+
+	return template.HTML(escaped)
+}
+
+func intParam(q url.Values, name string, def, lo, hi int) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("invalid %s %q, want %d to %d", name, v, lo, hi)
+	}
+
+	return n, nil
+}
+
+// pageLink is the current URL pointing at another page.
+func pageLink(u *url.URL, page int) string {
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+
+	return u.Path + "?" + q.Encode()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"xkcd"
+	"xkcd/comicstore"
+	"xkcd/index"
+)
+
+func testServer(t *testing.T) http.Handler {
+	t.Helper()
+	return testServerWithImages(t, "")
+}
+
+func testServerWithImages(t *testing.T, images string) http.Handler {
+	t.Helper()
+
+	comics := []xkcd.ComicDescription{
+		{Num: 1, Year: "2006", Month: "1", Day: "1", Title: "Barrel - Part 1", Img: "https://imgs.xkcd.com/comics/barrel_cropped_(1).jpg", Transcript: "A boy sits in a barrel which floats in the ocean."},
+		{Num: 2, Year: "2006", Month: "1", Day: "1", Title: "Petit Trees", Alt: "'Petit' being a reference to <Le Petit Prince>."},
+		{Num: 3, Year: "2006", Month: "1", Day: "1", Title: "Island", Alt: "Hello, island. A barrel?", Img: "https://imgs.xkcd.com/comics/island_color.jpg",
+			Image: &xkcd.ImageInfo{Path: "ab/abcd.jpeg", SHA256: "abcd", Size: 5, Width: 400, Height: 300}},
+		{Num: 5, Year: "2006", Month: "1", Day: "4", Title: "Blown apart", Alt: "A barrel, again."},
+	}
+
+	for i := range comics {
+		if err := comics[i].SetPublished(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := comicstore.OpenJSON(filepath.Join(t.TempDir(), "xkcd.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(comics...); err != nil {
+		t.Fatal(err)
+	}
+
+	var docs []index.Document
+	for _, c := range comics {
+		docs = append(docs, index.Document{Num: c.Num, Published: c.Published, Title: c.Title, Alt: c.Alt, Transcript: c.Transcript})
+	}
+
+	return newServer(store, index.Build(docs), index.BM25, images).routes()
+}
+
+func get(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+
+	return rec
+}
+
+func TestSearchAPI(t *testing.T) {
+	h := testServer(t)
+
+	rec := get(t, h, "/search?q=barrel&size=2")
+
+	var page resultPage
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+	}
+
+	if page.Total != 3 || page.Pages != 2 || len(page.Results) != 2 || page.Prev != "" || !strings.Contains(page.Next, "page=2") {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	if r := page.Results[0]; r.Img == "" || r.URL != "https://xkcd.com/"+strconv.Itoa(r.Num)+"/" || !strings.Contains(string(r.Snippet), "<mark>barrel</mark>") {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	next := page.Next
+	page = resultPage{}
+	json.Unmarshal(get(t, h, next).Body.Bytes(), &page)
+
+	if page.Page != 2 || len(page.Results) != 1 || page.Next != "" || page.Prev == "" {
+		t.Errorf("unexpected second page %+v", page)
+	}
+
+	for _, target := range []string{"/search", "/search?q=(barrel", "/search?q=barrel&size=1000", "/search?q=barrel&rank=best",
+		"/search?q=barrel&sort=random", "/search?q=barrel&since=yesterday", "/search?q=barrel&since=2007&until=2006"} {
+		if rec := get(t, h, target); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"error"`) {
+			t.Errorf("%s: got %d %s", target, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestSearchByDate(t *testing.T) {
+	h := testServer(t)
+
+	var page resultPage
+	json.Unmarshal(get(t, h, "/search?q=barrel&sort=newest").Body.Bytes(), &page)
+
+	var got []int
+	for _, r := range page.Results {
+		got = append(got, r.Num)
+	}
+
+	if !reflect.DeepEqual(got, []int{5, 1, 3}) || page.Results[0].Published != "2006-01-04" {
+		t.Errorf("newest first: got %v, %+v", got, page.Results)
+	}
+
+	page = resultPage{}
+	json.Unmarshal(get(t, h, "/search?q=barrel&since=2006-01-02&until=2006-01").Body.Bytes(), &page)
+
+	if page.Total != 1 || page.Results[0].Num != 5 {
+		t.Errorf("since the 2nd: got %+v", page)
+	}
+}
+
+func TestSnippetsAreEscaped(t *testing.T) {
+	h := testServer(t)
+
+	var page resultPage
+	json.Unmarshal(get(t, h, "/search?q=prince").Body.Bytes(), &page)
+
+	if len(page.Results) != 1 || string(page.Results[0].Snippet) != "&#39;Petit&#39; being a reference to &lt;Le Petit <mark>Prince</mark>&gt;." {
+		t.Errorf("got %+v", page.Results)
+	}
+}
+
+func TestComic(t *testing.T) {
+	h := testServer(t)
+
+	var comic xkcd.ComicDescription
+
+	rec := get(t, h, "/comic/3")
+	if err := json.Unmarshal(rec.Body.Bytes(), &comic); err != nil || comic.Title != "Island" {
+		t.Errorf("%d %s", rec.Code, rec.Body)
+	}
+
+	for _, target := range []string{"/comic/4", "/comic/x"} {
+		if rec := get(t, h, target); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	h := testServer(t)
+
+	rec := get(t, h, "/?q=barrel")
+	body := rec.Body.String()
+
+	if rec.Code != http.StatusOK || !strings.Contains(body, `<img src="https://imgs.xkcd.com/comics/barrel_cropped_%281%29.jpg"`) ||
+		!strings.Contains(body, "<mark>barrel</mark>") || !strings.Contains(body, "3 comics") {
+		t.Errorf("%d %s", rec.Code, body)
+	}
+
+	if rec := get(t, h, "/?q=velociraptor"); !strings.Contains(rec.Body.String(), "Nothing found") {
+		t.Errorf("no results: %s", rec.Body)
+	}
+
+	if rec := get(t, h, "/?q=(barrel"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "missing )") {
+		t.Errorf("bad query: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := get(t, h, "/"); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "Nothing found") {
+		t.Errorf("empty form: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestMirroredImages(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "ab"), 0o755)
+	os.WriteFile(filepath.Join(dir, "ab", "abcd.jpeg"), []byte("image"), 0o644)
+
+	h := testServerWithImages(t, dir)
+
+	var page resultPage
+	json.Unmarshal(get(t, h, "/search?q=island").Body.Bytes(), &page)
+
+	if len(page.Results) != 1 || page.Results[0].Img != "/images/ab/abcd.jpeg" || page.Results[0].Width != 400 {
+		t.Fatalf("got %+v", page.Results)
+	}
+
+	if rec := get(t, h, page.Results[0].Img); rec.Code != http.StatusOK || rec.Body.String() != "image" {
+		t.Errorf("image: %d %s", rec.Code, rec.Body)
+	}
+
+	// comics that aren't mirrored still link to xkcd.com
+	json.Unmarshal(get(t, h, "/search?q=boy").Body.Bytes(), &page)
+
+	if len(page.Results) != 1 || !strings.HasPrefix(page.Results[0].Img, "https://imgs.xkcd.com/") {
+		t.Errorf("got %+v", page.Results)
+	}
+
+	// and without a mirror, so do those that are
+	json.Unmarshal(get(t, testServer(t), "/search?q=island").Body.Bytes(), &page)
+
+	if page.Results[0].Img != "https://imgs.xkcd.com/comics/island_color.jpg" {
+		t.Errorf("got %+v", page.Results)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+
+	"xkcd"
+	"xkcd/comicstore"
+)
+
+// show prints one comic.
+func show(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	data := dataFlag(fs)
+	asJSON := fs.Bool("json", false, "print the comic as JSON, as it's stored")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("which comic? usage: xkcd show [flags] num")
+	}
+
+	num, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%q isn't a comic number", fs.Arg(0))
+	}
+
+	comics, err := openComics(*data)
+	if err != nil {
+		return err
+	}
+	defer comics.Close()
+
+	comic, err := comics.Get(num)
+	if errors.Is(err, comicstore.ErrNotFound) {
+		return fmt.Errorf("comic %d isn't in %s", num, *data)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printComic(os.Stdout, comic, *asJSON)
+}
+
+// random prints a comic picked at random.
+func random(args []string) error {
+	fs := flag.NewFlagSet("random", flag.ExitOnError)
+	data := dataFlag(fs)
+	asJSON := fs.Bool("json", false, "print the comic as JSON, as it's stored")
+	fs.Parse(args)
+
+	comics, err := openComics(*data)
+	if err != nil {
+		return err
+	}
+	defer comics.Close()
+
+	nums := comics.Nums()
+	if len(nums) == 0 {
+		return fmt.Errorf("there are no comics in %s", *data)
+	}
+
+	comic, err := comics.Get(nums[rand.IntN(len(nums))])
+	if err != nil {
+		return err
+	}
+
+	return printComic(os.Stdout, comic, *asJSON)
+}
+
+// printComic writes the comic for people to read, or as indented JSON.
+func printComic(w io.Writer, comic xkcd.ComicDescription, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(comic)
+	}
+
+	fmt.Fprintf(w, "#%d %s (%s)\n", comic.Num, comic.Title, day(comic.Published))
+	fmt.Fprintf(w, "https://xkcd.com/%d/\n", comic.Num)
+
+	if comic.Img != "" {
+		fmt.Fprintln(w, comic.Img)
+	}
+
+	if comic.Transcript != "" {
+		fmt.Fprintf(w, "\n%s\n", comic.Transcript)
+	}
+
+	if comic.Alt != "" {
+		fmt.Fprintf(w, "\nAlt: %s\n", comic.Alt)
+	}
+
+	return nil
+}
+
+// day formats a publication date as 2009-04-20.
+func day(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Format(time.DateOnly)
+}
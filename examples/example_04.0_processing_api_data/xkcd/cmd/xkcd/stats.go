@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"xkcd"
+)
+
+// comicStats is what stats reports about a set of comics.
+type comicStats struct {
+	Comics        int
+	First, Latest int
+	NotLoaded     int // between First and Latest, not counting the ones never published
+	NoTranscript  int
+	TranscriptLen int // average, in characters, of the comics that have one
+	Periods       []periodStats
+}
+
+type periodStats struct {
+	Start        time.Time // of the year or month, zero for comics without a date
+	Comics       int
+	NoTranscript int
+}
+
+// A grouping says which year or month a date is in, and how to show it.
+type grouping struct {
+	start  func(time.Time) time.Time
+	layout string
+}
+
+var groupings = map[string]grouping{
+	"year": {func(t time.Time) time.Time {
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}, "2006"},
+	"month": {func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}, "2006-01"},
+}
+
+// stats prints how many comics there are by year or month, and how many
+// lack a transcript.
+func stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	data := dataFlag(fs)
+	by := fs.String("by", "year", "count the comics by year or by month")
+	since := fs.String("since", "", "only comics published since this year, month or day (2010, 2010-04 or 2010-04-20)")
+	until := fs.String("until", "", "only comics published until the end of this year, month or day")
+	fs.Parse(args)
+
+	group, ok := groupings[*by]
+	if !ok {
+		return fmt.Errorf("can't count by %q, only by year or month", *by)
+	}
+
+	from, to, err := dateRange(*since, *until)
+	if err != nil {
+		return err
+	}
+
+	comics, err := openComics(*data)
+	if err != nil {
+		return err
+	}
+	defer comics.Close()
+
+	all, err := comics.All()
+	if err != nil {
+		return err
+	}
+
+	return printStats(os.Stdout, computeStats(within(all, from, to), group), group)
+}
+
+// within keeps the comics published on or after since and before until,
+// the way index.Within does with results.
+func within(comics []xkcd.ComicDescription, since, until time.Time) []xkcd.ComicDescription {
+	if since.IsZero() && until.IsZero() {
+		return comics
+	}
+
+	var kept []xkcd.ComicDescription
+	for _, comic := range comics {
+		switch t := comic.Published; {
+		case t.IsZero():
+		case !since.IsZero() && t.Before(since):
+		case !until.IsZero() && !t.Before(until):
+		default:
+			kept = append(kept, comic)
+		}
+	}
+	return kept
+}
+
+func computeStats(comics []xkcd.ComicDescription, group grouping) comicStats {
+	var (
+		s        comicStats
+		chars    int
+		byPeriod = map[time.Time]*periodStats{}
+		numbers  = map[int]bool{}
+	)
+
+	for _, comic := range comics {
+		if s.Comics == 0 || comic.Num < s.First {
+			s.First = comic.Num
+		}
+		s.Comics++
+		s.Latest = max(s.Latest, comic.Num)
+		numbers[comic.Num] = true
+
+		var start time.Time
+		if !comic.Published.IsZero() {
+			start = group.start(comic.Published)
+		}
+
+		p := byPeriod[start]
+		if p == nil {
+			p = &periodStats{Start: start}
+			byPeriod[start] = p
+		}
+		p.Comics++
+
+		if comic.Transcript == "" {
+			s.NoTranscript++
+			p.NoTranscript++
+		} else {
+			chars += utf8.RuneCountInString(comic.Transcript)
+		}
+	}
+
+	if with := s.Comics - s.NoTranscript; with > 0 {
+		s.TranscriptLen = chars / with
+	}
+
+	for num := s.First; num <= s.Latest; num++ {
+		if !numbers[num] && !comicsThatDontExist[num] {
+			s.NotLoaded++
+		}
+	}
+
+	for _, p := range byPeriod {
+		s.Periods = append(s.Periods, *p)
+	}
+
+	sort.Slice(s.Periods, func(i, j int) bool {
+		return s.Periods[i].Start.Before(s.Periods[j].Start)
+	})
+
+	return s
+}
+
+func printStats(w io.Writer, s comicStats, group grouping) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintf(w, "%d comics, from %d to %d", s.Comics, s.First, s.Latest)
+	if s.NotLoaded > 0 {
+		fmt.Fprintf(w, ", %d not loaded yet", s.NotLoaded)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "%d without a transcript, the others average %d characters\n\n", s.NoTranscript, s.TranscriptLen)
+
+	fmt.Fprintln(tw, "published\tcomics\tno transcript\t")
+	for _, p := range s.Periods {
+		period := "?"
+		if !p.Start.IsZero() {
+			period = p.Start.Format(group.layout)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", period, p.Comics, p.NoTranscript)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"xkcd"
+)
+
+func TestStats(t *testing.T) {
+	comics := []xkcd.ComicDescription{
+		{Num: 1, Published: date(2006, 1, 1), Transcript: "four"},
+		{Num: 2, Published: date(2006, 1, 4), Transcript: "sixsix"},
+		{Num: 3, Published: date(2007, 1, 1)},
+		{Num: 6, Published: date(2007, 5, 6), Transcript: "ünï"},
+		{Num: 7},
+	}
+
+	s := computeStats(comics, groupings["year"])
+
+	want := comicStats{
+		Comics:        5,
+		First:         1,
+		Latest:        7,
+		NotLoaded:     2, // 4 and 5
+		NoTranscript:  2,
+		TranscriptLen: (4 + 6 + 3) / 3,
+		Periods: []periodStats{
+			{Comics: 1, NoTranscript: 1},
+			{Start: date(2006, 1, 1), Comics: 2},
+			{Start: date(2007, 1, 1), Comics: 2, NoTranscript: 1},
+		},
+	}
+
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+
+	var out strings.Builder
+	if err := printStats(&out, s, groupings["year"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := out.String(); !strings.Contains(got, "2 not loaded yet") || !strings.Contains(got, "2007       2              1") {
+		t.Errorf("got\n%s", got)
+	}
+
+	// the ones since 2006-01-02, the undated one left out
+	s = computeStats(within(comics, date(2006, 1, 2), time.Time{}), groupings["month"])
+
+	if len(s.Periods) != 3 || s.Periods[0].Start != date(2006, 1, 1) || s.Periods[2].Start != date(2007, 5, 1) || s.Comics != 3 {
+		t.Errorf("by month: got %+v", s)
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
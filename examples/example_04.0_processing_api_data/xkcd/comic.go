@@ -0,0 +1,93 @@
+// Package xkcd is the model shared by the xkcd command and its packages:
+// a comic as xkcd.com describes it. comicstore keeps them on disk and
+// index searches them.
+package xkcd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ComicDescription is a comic as https://xkcd.com/<number>/info.0.json
+// describes it, plus what xkcd load adds.
+type ComicDescription struct {
+	Month      string `json:"month"`
+	Num        int    `json:"num"`
+	Link       string `json:"link"`
+	Year       string `json:"year"`
+	News       string `json:"news"`
+	Safe_title string `json:"safe_title"`
+	Transcript string `json:"transcript"`
+	Alt        string `json:"alt"`
+	Img        string `json:"img"`
+	Title      string `json:"title"`
+	Day        string `json:"day"`
+
+	Image *ImageInfo `json:"image,omitempty"` // set once the image is mirrored
+
+	// Published is the day the comic came out, from Year, Month and Day.
+	// Comics stored before there was such a field get it when they're read.
+	Published time.Time `json:"published"`
+}
+
+// The first comics are dated January 2006, though xkcd started in 2005.
+const firstYear = 2005
+
+// ParseDate reads the year, month and day xkcd.com gives a comic, as
+// strings, and checks they make a real day since xkcd started.
+func ParseDate(year, month, day string) (time.Time, error) {
+	y, errY := strconv.Atoi(year)
+	m, errM := strconv.Atoi(month)
+	d, errD := strconv.Atoi(day)
+
+	if errY != nil || errM != nil || errD != nil {
+		return time.Time{}, fmt.Errorf("bad date %q-%q-%q", year, month, day)
+	}
+
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+
+	// time.Date takes February 30 for March 2; we don't
+	if t.Year() != y || t.Month() != time.Month(m) || t.Day() != d || y < firstYear {
+		return time.Time{}, fmt.Errorf("bad date %s-%s-%s", year, month, day)
+	}
+
+	return t, nil
+}
+
+// SetPublished parses the date of the comic into Published.
+func (c *ComicDescription) SetPublished() error {
+	t, err := ParseDate(c.Year, c.Month, c.Day)
+	if err != nil {
+		return fmt.Errorf("comic %d: %w", c.Num, err)
+	}
+
+	c.Published = t
+	return nil
+}
+
+// UnmarshalJSON fills in Published when it's missing, if the date is good.
+func (c *ComicDescription) UnmarshalJSON(data []byte) error {
+	type plain ComicDescription // without this method
+
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Published.IsZero() {
+		c.Published, _ = ParseDate(c.Year, c.Month, c.Day)
+	}
+	return nil
+}
+
+// ImageInfo describes the local copy of a comic's image. The file is
+// named after its checksum, so the same image is only ever stored once
+// and a file that's there is known to be complete.
+type ImageInfo struct {
+	Path   string `json:"path"` // relative to the mirror directory, like "3f/3fa2….png"
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
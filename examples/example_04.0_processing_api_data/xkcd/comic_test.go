@@ -0,0 +1,51 @@
+package xkcd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	for _, tt := range []struct {
+		year, month, day string
+		ok               bool
+	}{
+		{"2009", "4", "20", true},
+		{"2006", "01", "01", true},
+		{"2012", "2", "29", true},
+		{"2013", "2", "29", false},
+		{"2010", "13", "1", false},
+		{"2010", "0", "1", false},
+		{"1999", "1", "1", false},
+		{"", "", "", false},
+		{"2010", "April", "1", false},
+	} {
+		got, err := ParseDate(tt.year, tt.month, tt.day)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s-%s-%s: got %v, %v", tt.year, tt.month, tt.day, got, err)
+		}
+	}
+}
+
+func TestPublishedFilledIn(t *testing.T) {
+	// the way xkcd.com, and the files written before Published, have it
+	var c ComicDescription
+	if err := json.Unmarshal([]byte(`{"num":571,"year":"2009","month":"4","day":"20"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2009, 4, 20, 0, 0, 0, 0, time.UTC); !c.Published.Equal(want) {
+		t.Errorf("got %v, want %v", c.Published, want)
+	}
+
+	// a bad date is left for SetPublished to report
+	c = ComicDescription{}
+	if err := json.Unmarshal([]byte(`{"num":1,"year":"2009","month":"2","day":"31"}`), &c); err != nil || !c.Published.IsZero() {
+		t.Errorf("got %v, %v", c.Published, err)
+	}
+
+	if err := c.SetPublished(); err == nil {
+		t.Error("no error for February 31")
+	}
+}
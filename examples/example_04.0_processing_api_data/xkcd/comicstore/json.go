@@ -0,0 +1,153 @@
+package comicstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"xkcd"
+)
+
+// JSONStore keeps all the comics in memory and on disk as one JSON array,
+// sorted by number.
+type JSONStore struct {
+	path string
+
+	mu     sync.RWMutex
+	comics map[int]xkcd.ComicDescription
+}
+
+// OpenJSON reads the comics at path; a file that doesn't exist yet just
+// means there are none.
+func OpenJSON(path string) (*JSONStore, error) {
+	s := &JSONStore{path: path, comics: map[int]xkcd.ComicDescription{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var comics []xkcd.ComicDescription
+	if err := json.Unmarshal(data, &comics); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, comic := range comics {
+		s.comics[comic.Num] = comic
+	}
+	return s, nil
+}
+
+func (s *JSONStore) Get(num int) (xkcd.ComicDescription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comic, ok := s.comics[num]
+	if !ok {
+		return xkcd.ComicDescription{}, ErrNotFound
+	}
+	return comic, nil
+}
+
+// Put rewrites the whole file. If that fails, the store is left as it was.
+func (s *JSONStore) Put(comics ...xkcd.ComicDescription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := maps.Clone(s.comics)
+	for _, comic := range comics {
+		updated[comic.Num] = comic
+	}
+
+	if err := write(s.path, updated); err != nil {
+		return err
+	}
+
+	s.comics = updated
+	return nil
+}
+
+func (s *JSONStore) All() ([]xkcd.ComicDescription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sorted(s.comics), nil
+}
+
+func (s *JSONStore) Nums() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nums := make([]int, 0, len(s.comics))
+	for num := range s.comics {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+func (s *JSONStore) Close() error { return nil }
+
+func sorted(byNum map[int]xkcd.ComicDescription) []xkcd.ComicDescription {
+	comics := make([]xkcd.ComicDescription, 0, len(byNum))
+	for _, comic := range byNum {
+		comics = append(comics, comic)
+	}
+
+	sort.Slice(comics, func(i, j int) bool {
+		return comics[i].Num < comics[j].Num
+	})
+	return comics
+}
+
+// write replaces the file at path with the comics. It writes a temporary
+// file next to it and renames that over the old one, so an interrupted run
+// never leaves half a file behind.
+func write(path string, comics map[int]xkcd.ComicDescription) error {
+	jsonData, err := json.Marshal(sorted(comics))
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, jsonData)
+}
+
+// writeFile atomically replaces the file at path with data.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once it's renamed
+
+	// CreateTemp makes it readable by us only
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
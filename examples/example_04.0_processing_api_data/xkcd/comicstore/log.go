@@ -0,0 +1,370 @@
+package comicstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"xkcd"
+)
+
+// LogStore appends comics to a file, one JSON object per line, and never
+// changes what's written: putting a comic again appends a newer version.
+// Next to it, PATH.idx lists the comic number, offset and length of every
+// line. That index is only a cache: whatever it's missing, like the lines
+// written after a crash, is found again by reading the end of the log.
+type LogStore struct {
+	path string
+
+	mu      sync.RWMutex
+	log     *os.File
+	idx     *os.File
+	size    int64 // of the log, where the next line goes
+	idxSize int64
+	stale   bool // the index missed some lines, so it stops here until the next open
+	lines   int  // in the log, including replaced ones
+	offsets map[int]extent
+}
+
+type extent struct {
+	offset int64
+	length int32 // without the newline
+}
+
+// an index record: comic number, offset and length
+const recordSize = 8 + 8 + 4
+
+// Compacter is a store that can drop the old versions of its comics.
+type Compacter interface {
+	Compact() error
+}
+
+// OpenLog opens the log at path and its index, creating them if they don't
+// exist. A line left half written by a crash is dropped.
+func OpenLog(path string) (*LogStore, error) {
+	s := &LogStore{path: path}
+
+	if err := s.open(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *LogStore) open() error {
+	var err error
+
+	if s.log, err = os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o644); err != nil {
+		return err
+	}
+
+	if s.idx, err = os.OpenFile(s.path+".idx", os.O_RDWR|os.O_CREATE, 0o644); err != nil {
+		return err
+	}
+
+	s.offsets = map[int]extent{}
+	s.lines = 0
+	s.stale = false
+
+	covered, err := s.readIndex()
+	if err != nil {
+		return err
+	}
+
+	return s.scan(covered)
+}
+
+// readIndex loads the index and returns how much of the log it covers. An
+// index that doesn't fit the log is thrown away.
+func (s *LogStore) readIndex() (int64, error) {
+	logInfo, err := s.log.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(s.idx, 0, 1<<62))
+	if err != nil {
+		return 0, err
+	}
+
+	covered := int64(0)
+
+	for len(data) >= recordSize {
+		num := int(binary.LittleEndian.Uint64(data))
+		e := extent{int64(binary.LittleEndian.Uint64(data[8:])), int32(binary.LittleEndian.Uint32(data[16:]))}
+		data = data[recordSize:]
+
+		s.offsets[num] = e
+		s.lines++
+		covered = max(covered, e.offset+int64(e.length)+1)
+	}
+
+	if covered > logInfo.Size() {
+		s.offsets, s.lines, covered = map[int]extent{}, 0, 0
+	}
+
+	// a record cut short, or all of them when we start over
+	s.idxSize = int64(s.lines) * recordSize
+	return covered, s.idx.Truncate(s.idxSize)
+}
+
+// scan reads the log from offset on, indexing the lines it finds there.
+func (s *LogStore) scan(offset int64) error {
+	r := bufio.NewReader(io.NewSectionReader(s.log, offset, 1<<62))
+	var records []byte
+
+	for {
+		line, err := r.ReadBytes('\n')
+
+		if err == io.EOF {
+			if len(line) > 0 {
+				// the last line never got its newline: it's incomplete
+				if err := s.log.Truncate(offset); err != nil {
+					return err
+				}
+			}
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var head struct {
+			Num int `json:"num"`
+		}
+
+		if err := json.Unmarshal(line, &head); err != nil {
+			return fmt.Errorf("%s at %d: %w", s.path, offset, err)
+		}
+
+		e := extent{offset, int32(len(line) - 1)}
+		s.offsets[head.Num] = e
+		s.lines++
+		records = appendRecord(records, head.Num, e)
+		offset += int64(len(line))
+	}
+
+	s.size = offset
+	return s.appendIndex(records)
+}
+
+func appendRecord(b []byte, num int, e extent) []byte {
+	b = binary.LittleEndian.AppendUint64(b, uint64(num))
+	b = binary.LittleEndian.AppendUint64(b, uint64(e.offset))
+	return binary.LittleEndian.AppendUint32(b, uint32(e.length))
+}
+
+func (s *LogStore) appendIndex(records []byte) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if _, err := s.idx.WriteAt(records, s.idxSize); err != nil {
+		return err
+	}
+
+	s.idxSize += int64(len(records))
+	return nil
+}
+
+// Get reads just comic num from the log.
+func (s *LogStore) Get(num int) (xkcd.ComicDescription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.get(num)
+}
+
+func (s *LogStore) get(num int) (xkcd.ComicDescription, error) {
+	e, ok := s.offsets[num]
+	if !ok {
+		return xkcd.ComicDescription{}, ErrNotFound
+	}
+
+	line := make([]byte, e.length)
+	if _, err := s.log.ReadAt(line, e.offset); err != nil {
+		return xkcd.ComicDescription{}, err
+	}
+
+	var comic xkcd.ComicDescription
+	if err := json.Unmarshal(line, &comic); err != nil {
+		return xkcd.ComicDescription{}, fmt.Errorf("%s at %d: %w", s.path, e.offset, err)
+	}
+
+	if comic.Num != num {
+		return xkcd.ComicDescription{}, fmt.Errorf("%s at %d: found comic %d instead of %d, the index is broken", s.path, e.offset, comic.Num, num)
+	}
+
+	return comic, nil
+}
+
+// Put appends the comics to the log, and syncs it before it returns.
+func (s *LogStore) Put(comics ...xkcd.ComicDescription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		lines   bytes.Buffer
+		records []byte
+	)
+
+	offsets := map[int]extent{}
+	offset := s.size
+
+	for _, comic := range comics {
+		line, err := json.Marshal(comic)
+		if err != nil {
+			return err
+		}
+
+		e := extent{offset, int32(len(line))}
+		offsets[comic.Num] = e
+		records = appendRecord(records, comic.Num, e)
+
+		lines.Write(line)
+		lines.WriteByte('\n')
+		offset += int64(len(line)) + 1
+	}
+
+	if _, err := s.log.WriteAt(lines.Bytes(), s.size); err != nil {
+		return err
+	}
+
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+
+	// they're safe now; the index can always be rebuilt
+	for num, e := range offsets {
+		s.offsets[num] = e
+	}
+
+	s.size = offset
+	s.lines += len(comics)
+
+	// the comics are stored even if the index doesn't get them: the next
+	// open finds the lines it's missing, as long as nothing is indexed
+	// after them in the meantime
+	if s.stale {
+		return nil
+	}
+
+	if err := s.appendIndex(records); err != nil {
+		log.Printf("%s: the index will be rebuilt on the next open: %v", s.path, err)
+		s.stale = true
+	}
+
+	return nil
+}
+
+func (s *LogStore) All() ([]xkcd.ComicDescription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var comics []xkcd.ComicDescription
+
+	for _, num := range s.nums() {
+		comic, err := s.get(num)
+		if err != nil {
+			return nil, err
+		}
+		comics = append(comics, comic)
+	}
+
+	return comics, nil
+}
+
+func (s *LogStore) Nums() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.nums()
+}
+
+func (s *LogStore) nums() []int {
+	nums := make([]int, 0, len(s.offsets))
+	for num := range s.offsets {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// Stale is the number of lines in the log that newer ones replaced.
+func (s *LogStore) Stale() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lines - len(s.offsets)
+}
+
+// Compact rewrites the log with the latest version of every comic only, in
+// number order. The index is removed before the new log replaces the old
+// one, so a crash in between leaves a log that gets indexed again.
+func (s *LogStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lines bytes.Buffer
+
+	for _, num := range s.nums() {
+		e := s.offsets[num]
+		line := make([]byte, e.length)
+
+		if _, err := s.log.ReadAt(line, e.offset); err != nil {
+			return err
+		}
+
+		lines.Write(line)
+		lines.WriteByte('\n')
+	}
+
+	if err := writeFile(s.path+".compact", lines.Bytes()); err != nil {
+		return err
+	}
+
+	s.closeFiles()
+
+	if err := os.Remove(s.path + ".idx"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Rename(s.path+".compact", s.path); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *LogStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closeFiles()
+}
+
+func (s *LogStore) closeFiles() error {
+	var errs []error
+
+	if s.log != nil {
+		errs = append(errs, s.log.Close())
+		s.log = nil
+	}
+
+	if s.idx != nil {
+		errs = append(errs, s.idx.Close())
+		s.idx = nil
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,59 @@
+// Package comicstore keeps the xkcd comic descriptions on disk, in one of
+// two formats:
+//
+//   - a JSON array (xkcd.json), the original format: simple, but every
+//     change rewrites the whole file and every reader parses all of it;
+//   - an append-only log of one JSON comic per line (xkcd.ndjson) with an
+//     index of where each comic is, so one comic can be read without
+//     parsing the others and new comics are appended without rewriting.
+//
+// Open picks the format from the file name; both are a ComicStore.
+package comicstore
+
+import (
+	"errors"
+	"path/filepath"
+
+	"xkcd"
+)
+
+// ErrNotFound is what Get returns for a comic that isn't stored.
+var ErrNotFound = errors.New("comic not found")
+
+// ComicStore is a set of comics by number. Implementations are safe for
+// concurrent use.
+type ComicStore interface {
+	// Get returns comic num, or ErrNotFound.
+	Get(num int) (xkcd.ComicDescription, error)
+
+	// Put stores comics, replacing the ones with the same number. It's
+	// cheaper to put many at once than one at a time.
+	Put(comics ...xkcd.ComicDescription) error
+
+	// All returns every comic, by number.
+	All() ([]xkcd.ComicDescription, error)
+
+	// Nums returns the numbers of the comics stored, in increasing order.
+	Nums() []int
+
+	// Close releases the files; the store can't be used after that.
+	Close() error
+}
+
+// Open opens the store at path, creating it if it doesn't exist: a log
+// for a .ndjson file, a JSON array for anything else.
+func Open(path string) (ComicStore, error) {
+	if filepath.Ext(path) == ".ndjson" {
+		return OpenLog(path)
+	}
+	return OpenJSON(path)
+}
+
+// Copy puts all the comics of src into dst, to convert between formats.
+func Copy(dst, src ComicStore) error {
+	comics, err := src.All()
+	if err != nil {
+		return err
+	}
+	return dst.Put(comics...)
+}
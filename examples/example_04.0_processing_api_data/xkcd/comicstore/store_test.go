@@ -0,0 +1,234 @@
+package comicstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"xkcd"
+)
+
+func comic(num int, title string) xkcd.ComicDescription {
+	return xkcd.ComicDescription{Num: num, Title: title, Transcript: "line one\nline two"}
+}
+
+func TestStores(t *testing.T) {
+	for _, name := range []string{"xkcd.json", "xkcd.ndjson"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+
+			s, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.Get(1); !errors.Is(err, ErrNotFound) {
+				t.Errorf("empty store: got %v", err)
+			}
+
+			if err := s.Put(comic(3, "three"), comic(1, "one")); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.Put(comic(2, "two"), comic(3, "three again")); err != nil {
+				t.Fatal(err)
+			}
+
+			s.Close()
+
+			// everything is still there when it's opened again
+			if s, err = Open(path); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if got, err := s.Get(3); err != nil || !reflect.DeepEqual(got, comic(3, "three again")) {
+				t.Errorf("got %+v, %v", got, err)
+			}
+
+			if got := s.Nums(); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+				t.Errorf("got nums %v", got)
+			}
+
+			all, err := s.All()
+			if err != nil || len(all) != 3 || all[0].Title != "one" || all[2].Title != "three again" {
+				t.Errorf("got %+v, %v", all, err)
+			}
+		})
+	}
+}
+
+func TestLogRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xkcd.ndjson")
+
+	s, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Put(comic(1, "one"), comic(2, "two"))
+	s.Close()
+
+	// a crash halfway through the next line
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"num":3,"tit`)
+	f.Close()
+
+	if s, err = OpenLog(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.Nums(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("after a torn write: %v", got)
+	}
+
+	// the next line goes where the torn one was
+	s.Put(comic(3, "three"))
+	s.Close()
+
+	// without an index, or with one that's behind, the log is read again
+	os.Remove(path + ".idx")
+
+	if s, err = OpenLog(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := s.Get(3); err != nil || got.Title != "three" {
+		t.Errorf("without an index: %+v, %v", got, err)
+	}
+
+	s.Close()
+
+	idx, _ := os.ReadFile(path + ".idx")
+	os.WriteFile(path+".idx", idx[:recordSize+5], 0o644)
+
+	if s, err = OpenLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got := s.Nums(); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("with a short index: %v", got)
+	}
+}
+
+func TestIndexFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xkcd.ndjson")
+
+	s, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Put(comic(1, "one"))
+
+	// the index can't be written, but the log can
+	s.idx.Close()
+
+	if err := s.Put(comic(2, "two")); err != nil {
+		t.Errorf("the comic is in the log: %v", err)
+	}
+
+	// a retry would put it in the log twice
+	if s.Stale() != 0 {
+		t.Errorf("got %d stale lines, want 0", s.Stale())
+	}
+
+	s.Put(comic(3, "three"))
+	s.Close()
+
+	if s, err = OpenLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got := s.Nums(); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("after a failed index write: %v", got)
+	}
+}
+
+func TestJSONWriteFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "gone")
+	os.Mkdir(dir, 0o755)
+
+	s, err := OpenJSON(filepath.Join(dir, "xkcd.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Put(comic(1, "one"))
+	os.RemoveAll(dir)
+
+	if err := s.Put(comic(1, "one again"), comic(2, "two")); err == nil {
+		t.Fatal("no error without a directory")
+	}
+
+	if got := s.Nums(); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("after a failed write: %v", got)
+	}
+
+	if got, _ := s.Get(1); got.Title != "one" {
+		t.Errorf("after a failed write: %+v", got)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xkcd.ndjson")
+
+	s, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 3; i++ {
+		s.Put(comic(2, "two"), comic(1, "one"))
+	}
+
+	before, _ := os.Stat(path)
+
+	if s.Stale() != 4 {
+		t.Errorf("got %d stale lines, want 4", s.Stale())
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	after, _ := os.Stat(path)
+
+	if s.Stale() != 0 || after.Size() >= before.Size() {
+		t.Errorf("%d stale lines, %d bytes, was %d", s.Stale(), after.Size(), before.Size())
+	}
+
+	if got, err := s.All(); err != nil || len(got) != 2 || got[0].Num != 1 {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	// it's still a log that can be appended to
+	s.Put(comic(3, "three"))
+
+	if got, err := s.Get(3); err != nil || got.Title != "three" {
+		t.Errorf("got %+v, %v", got, err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	dir := t.TempDir()
+
+	src, _ := OpenJSON(filepath.Join(dir, "xkcd.json"))
+	src.Put(comic(1, "one"), comic(2, "two"))
+
+	dst, _ := OpenLog(filepath.Join(dir, "xkcd.ndjson"))
+	defer dst.Close()
+
+	if err := Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := dst.Nums(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,47 @@
+package fake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"xkcd"
+)
+
+var words = strings.Fields(`barrel ocean island tree velociraptor physics
+	chemistry math graph password horse battery staple computer science
+	sleep bed internet cat hat stick figure science kite rocket moon`)
+
+// Comics makes n comics the way xkcd.com describes them, numbered from 1,
+// three a week from January 2006, with titles, alt texts and transcripts
+// made of a few words. Like on xkcd.com there's no comic 404.
+func Comics(n int) []xkcd.ComicDescription {
+	var comics []xkcd.ComicDescription
+	start := time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC) // a Monday
+
+	for num := 1; num <= n; num++ {
+		if num == 404 {
+			continue
+		}
+
+		// Monday, Wednesday and Friday
+		day := start.AddDate(0, 0, (num-1)/3*7+(num-1)%3*2)
+		word := func(i int) string { return words[(num*7+i*3)%len(words)] }
+		title := fmt.Sprintf("The %s and the %s", word(0), word(1))
+
+		comics = append(comics, xkcd.ComicDescription{
+			Num:        num,
+			Year:       strconv.Itoa(day.Year()),
+			Month:      strconv.Itoa(int(day.Month())),
+			Day:        strconv.Itoa(day.Day()),
+			Title:      title,
+			Safe_title: title,
+			Alt:        fmt.Sprintf("Never trust a %s near a %s.", word(2), word(3)),
+			Transcript: fmt.Sprintf("[[A %s looks at a %s.]]\n%s: Is that a %s?", word(1), word(0), word(4), word(2)),
+			Img:        fmt.Sprintf("https://imgs.xkcd.com/comics/comic_%d.png", num),
+		})
+	}
+
+	return comics
+}
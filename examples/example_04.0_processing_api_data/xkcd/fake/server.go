@@ -0,0 +1,184 @@
+// Package fake is an in-process stand-in for xkcd.com. It serves
+// /info.0.json and /{num}/info.0.json from fixture comics, answers 404 for
+// the ones it doesn't have, and can make any comic slow, fail or come back
+// as broken JSON:
+//
+//	f := fake.New(fake.Comics(50))
+//	f.Fail(7, fake.Fault{Status: 503, Times: 2})
+//
+//	srv := httptest.NewServer(f)
+//	defer srv.Close()
+//
+// then point the loader at srv.URL instead of https://xkcd.com.
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"xkcd"
+)
+
+// Fault makes the server misbehave for one comic. The zero value means no
+// fault.
+type Fault struct {
+	Delay     time.Duration // before answering
+	Status    int           // answered instead of the comic, if not zero
+	Malformed bool          // answer 200 with JSON cut short
+	Times     int           // requests it applies to before the comic is served, 0 for all of them
+}
+
+// Server is an http.Handler serving xkcd's JSON API. It's safe for
+// concurrent use.
+type Server struct {
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	comics   map[int]xkcd.ComicDescription
+	latest   int
+	latency  time.Duration
+	faults   map[int]Fault
+	requests map[int]int
+	inFlight int
+	peak     int
+}
+
+// New returns a server for the comics; the one with the highest number is
+// the latest.
+func New(comics []xkcd.ComicDescription) *Server {
+	s := &Server{
+		mux:      http.NewServeMux(),
+		comics:   map[int]xkcd.ComicDescription{},
+		faults:   map[int]Fault{},
+		requests: map[int]int{},
+	}
+
+	for _, comic := range comics {
+		s.comics[comic.Num] = comic
+		s.latest = max(s.latest, comic.Num)
+	}
+
+	s.mux.HandleFunc("GET /info.0.json", func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, 0)
+	})
+
+	s.mux.HandleFunc("GET /{num}/info.0.json", func(w http.ResponseWriter, r *http.Request) {
+		num, err := strconv.Atoi(r.PathValue("num"))
+		if err != nil || num < 1 {
+			http.NotFound(w, r)
+			return
+		}
+		s.serve(w, r, num)
+	})
+
+	return s
+}
+
+// SetLatency adds d to every request from now on.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency = d
+	s.mu.Unlock()
+}
+
+// Fail makes the requests for comic num, or for the latest one with 0,
+// misbehave the way f says, replacing any fault it had.
+func (s *Server) Fail(num int, f Fault) {
+	s.mu.Lock()
+	s.faults[num] = f
+	s.mu.Unlock()
+}
+
+// Requests is how many times comic num, or the latest one for 0, was asked
+// for.
+func (s *Server) Requests(num int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[num]
+}
+
+// Peak is the largest number of requests the server was answering at once.
+func (s *Server) Peak() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.peak
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, num int) {
+	s.mu.Lock()
+	s.requests[num]++
+	s.inFlight++
+	s.peak = max(s.peak, s.inFlight)
+
+	f, faulty := s.faults[num]
+	if faulty && f.Times > 0 {
+		if f.Times--; f.Times == 0 {
+			delete(s.faults, num)
+		} else {
+			s.faults[num] = f
+		}
+	}
+
+	latency := s.latency
+	comic, ok := s.comics[num]
+	if num == 0 {
+		comic, ok = s.comics[s.latest]
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(latency + f.Delay):
+	case <-r.Context().Done():
+		return
+	}
+
+	switch {
+	case f.Status != 0:
+		http.Error(w, http.StatusText(f.Status), f.Status)
+
+	case !ok:
+		http.NotFound(w, r)
+
+	case f.Malformed:
+		data, _ := json.Marshal(wire(comic))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data[:len(data)/2])
+
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wire(comic))
+	}
+}
+
+// wire is the comic with only the fields xkcd.com sends, leaving out what
+// the loader adds.
+func wire(c xkcd.ComicDescription) any {
+	return struct {
+		Month      string `json:"month"`
+		Num        int    `json:"num"`
+		Link       string `json:"link"`
+		Year       string `json:"year"`
+		News       string `json:"news"`
+		Safe_title string `json:"safe_title"`
+		Transcript string `json:"transcript"`
+		Alt        string `json:"alt"`
+		Img        string `json:"img"`
+		Title      string `json:"title"`
+		Day        string `json:"day"`
+	}{c.Month, c.Num, c.Link, c.Year, c.News, c.Safe_title, c.Transcript, c.Alt, c.Img, c.Title, c.Day}
+}
@@ -0,0 +1,89 @@
+package fake_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"xkcd"
+	"xkcd/fake"
+)
+
+func get(t *testing.T, url string) (int, xkcd.ComicDescription, error) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var comic xkcd.ComicDescription
+	err = json.NewDecoder(resp.Body).Decode(&comic)
+	return resp.StatusCode, comic, err
+}
+
+func TestComics(t *testing.T) {
+	f := fake.New(fake.Comics(410))
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	status, latest, err := get(t, srv.URL+"/info.0.json")
+	if status != http.StatusOK || err != nil || latest.Num != 410 {
+		t.Errorf("latest: %d %+v %v", status, latest, err)
+	}
+
+	status, comic, err := get(t, srv.URL+"/5/info.0.json")
+	if status != http.StatusOK || err != nil || comic.Num != 5 || comic.Title == "" || comic.SetPublished() != nil {
+		t.Errorf("comic 5: %d %+v %v", status, comic, err)
+	}
+
+	// Wednesday of the second week
+	if want := time.Date(2006, 1, 11, 0, 0, 0, 0, time.UTC); !comic.Published.Equal(want) {
+		t.Errorf("comic 5 published %v, want %v", comic.Published, want)
+	}
+
+	for _, path := range []string{"/404/info.0.json", "/411/info.0.json", "/0/info.0.json", "/x/info.0.json"} {
+		if status, _, _ := get(t, srv.URL+path); status != http.StatusNotFound {
+			t.Errorf("%s: got %d", path, status)
+		}
+	}
+
+	if f.Requests(5) != 1 || f.Requests(0) != 1 {
+		t.Errorf("counted %d and %d requests", f.Requests(5), f.Requests(0))
+	}
+}
+
+func TestFaults(t *testing.T) {
+	f := fake.New(fake.Comics(10))
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	f.Fail(1, fake.Fault{Status: http.StatusServiceUnavailable, Times: 2})
+	f.Fail(2, fake.Fault{Malformed: true})
+	f.Fail(3, fake.Fault{Delay: 50 * time.Millisecond, Times: 1})
+
+	for i, want := range []int{503, 503, 200} {
+		if status, _, _ := get(t, srv.URL+"/1/info.0.json"); status != want {
+			t.Errorf("request %d: got %d, want %d", i+1, status, want)
+		}
+	}
+
+	if status, _, err := get(t, srv.URL+"/2/info.0.json"); status != http.StatusOK || err == nil {
+		t.Errorf("malformed: got %d, %v", status, err)
+	}
+
+	start := time.Now()
+	get(t, srv.URL+"/3/info.0.json")
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("slow comic took %v", d)
+	}
+
+	start = time.Now()
+	get(t, srv.URL+"/3/info.0.json")
+	if d := time.Since(start); d >= 50*time.Millisecond {
+		t.Errorf("comic 3 is still slow: %v", d)
+	}
+}
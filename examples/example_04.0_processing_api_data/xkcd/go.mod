@@ -0,0 +1,3 @@
+module xkcd
+
+go 1.22.3
@@ -0,0 +1,98 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a term and where it was in the text. Stopwords take up a
+// position without being indexed, so "cat in the hat" still needs the two
+// words three positions apart.
+type token struct {
+	term string
+	pos  int
+}
+
+// analyze splits text into lowercase words, drops stopwords and stems the
+// rest. The index and the queries go through the same analysis, so they
+// agree on what a term is.
+func analyze(text string) []token {
+	var tokens []token
+
+	for i, word := range words(text) {
+		if stopwords[word] {
+			continue
+		}
+
+		tokens = append(tokens, token{stem(word), i})
+	}
+
+	return tokens
+}
+
+// words splits text on anything but letters and digits, lowercased.
+func words(text string) []string {
+	var out []string
+	for _, sp := range spans(text) {
+		out = append(out, sp.word)
+	}
+	return out
+}
+
+// span is a word and the bytes of the text it came from.
+type span struct {
+	word       string
+	start, end int
+}
+
+// spans finds the words of text. Apostrophes inside a word are dropped
+// rather than split on, so "don't" is "dont".
+func spans(text string) []span {
+	var (
+		out   []span
+		b     strings.Builder
+		start int
+	)
+
+	flush := func(end int) {
+		if b.Len() > 0 {
+			out = append(out, span{b.String(), start, end})
+			b.Reset()
+		}
+	}
+
+	end := 0
+
+	for i, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if b.Len() == 0 {
+				start = i
+			}
+			b.WriteRune(unicode.ToLower(r))
+			end = i + utf8.RuneLen(r)
+		case (r == '\'' || r == '’') && b.Len() > 0:
+		default:
+			flush(end)
+		}
+	}
+
+	flush(end)
+
+	return out
+}
+
+// stopwords are too common to tell comics apart.
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		a about an and are as at be been but by can could did do does for from
+		had has have he her him his how i if in into is it its just me my no
+		not of on or our she so than that the their them then there these
+		they this to too up us was we were what when where which who why will
+		with would you your`) {
+		stopwords[w] = true
+	}
+}
@@ -0,0 +1,42 @@
+package index
+
+// withinDistance says if a can be turned into b with at most max single
+// letter insertions, deletions or substitutions (Levenshtein distance).
+// It gives up as soon as a whole row of the table is over max.
+func withinDistance(a, b string, max int) bool {
+	ra, rb := []rune(a), []rune(b)
+
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return false
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			best = min(best, cur[j])
+		}
+
+		if best > max {
+			return false
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)] <= max
+}
@@ -0,0 +1,217 @@
+// Package index is a full-text index over the xkcd comics: an inverted
+// index from the stemmed terms of the title, alt text and transcript of
+// each comic to the places they occur, with queries such as
+//
+//	velociraptor OR dinosaur
+//	"password strength" -correct
+//	(physics OR chemistry) AND NOT math
+//
+// or, in a field or in a range of years or numbers,
+//
+//	title:"little bobby tables"
+//	alt:velociraptr~ year:2007..2009
+//
+// ranked by BM25 or TF-IDF.
+package index
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Document is what gets indexed of a comic.
+type Document struct {
+	Num        int
+	Published  time.Time // zero if unknown, which no date or year range matches
+	Title      string
+	Alt        string
+	Transcript string
+}
+
+// The fields of a document, in the order they're laid out in.
+const (
+	Title = iota
+	Alt
+	Transcript
+	numFields
+)
+
+// version changes whenever the layout of Index does, so old index files
+// get rebuilt rather than misread.
+const version = 3
+
+// Index maps terms to the documents they occur in. The fields of a
+// document are numbered as one run of positions, title first, with a gap
+// between fields so a phrase can't start in one field and end in the next.
+type Index struct {
+	Version int
+	Docs    []Doc
+	Terms   map[string][]Posting
+	AvgLen  float64 // average number of indexed terms per document
+}
+
+// Doc is a document in the index.
+type Doc struct {
+	Num       int
+	Year      int // of Published, 0 if it isn't known
+	Published time.Time
+	Title     string
+	Len       int            // number of indexed terms
+	Starts    [numFields]int // position the fields start at
+}
+
+// Posting lists where a term occurs in one document, positions in
+// increasing order.
+type Posting struct {
+	Doc       int // index into Docs
+	Positions []int
+}
+
+// Build indexes docs.
+func Build(docs []Document) *Index {
+	ix := &Index{Version: version, Terms: map[string][]Posting{}}
+	total := 0
+
+	for _, d := range docs {
+		doc := Doc{Num: d.Num, Published: d.Published, Title: d.Title}
+		if !d.Published.IsZero() {
+			doc.Year = d.Published.Year()
+		}
+		id := len(ix.Docs)
+		positions := map[string][]int{}
+		next := 0
+
+		for field, text := range [numFields]string{d.Title, d.Alt, d.Transcript} {
+			doc.Starts[field] = next
+			n := 0
+
+			for _, tok := range analyze(text) {
+				positions[tok.term] = append(positions[tok.term], next+tok.pos)
+				n = tok.pos + 1
+				doc.Len++
+			}
+
+			next += n + 1
+		}
+
+		for term, pos := range positions {
+			ix.Terms[term] = append(ix.Terms[term], Posting{Doc: id, Positions: pos})
+		}
+
+		ix.Docs = append(ix.Docs, doc)
+		total += doc.Len
+	}
+
+	if len(ix.Docs) > 0 {
+		ix.AvgLen = float64(total) / float64(len(ix.Docs))
+	}
+
+	return ix
+}
+
+// fieldAt says which field position pos is in.
+func (d Doc) fieldAt(pos int) int {
+	for field := numFields - 1; field > 0; field-- {
+		if pos >= d.Starts[field] {
+			return field
+		}
+	}
+	return Title
+}
+
+// inField says if p has a position in field.
+func (ix *Index) inField(p Posting, field int) bool {
+	for _, pos := range p.Positions {
+		if ix.Docs[p.Doc].fieldAt(pos) == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the index to path, by way of a temporary file so a reader
+// never sees half an index.
+func (ix *Index) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// a half-written index left behind would never be loaded, only pile up
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(ix); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// ErrStale is what Load returns for an index written by another version of
+// this package; it needs to be built again.
+var ErrStale = errors.New("index is out of date")
+
+// Load reads an index Save wrote.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ix Index
+
+	if err := gob.NewDecoder(f).Decode(&ix); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if ix.Version != version {
+		return nil, fmt.Errorf("%s: %w", path, ErrStale)
+	}
+
+	return &ix, nil
+}
+
+// LoadOrBuild loads the index at path if it's newer than the data file it
+// was built from, and builds it from the documents docs returns otherwise,
+// saving it for next time.
+func LoadOrBuild(path, data string, docs func() ([]Document, error)) (*Index, error) {
+	if ix, err := load(path, data); err == nil {
+		return ix, nil
+	}
+
+	d, err := docs()
+	if err != nil {
+		return nil, err
+	}
+
+	ix := Build(d)
+
+	return ix, ix.Save(path)
+}
+
+func load(path, data string) (*Index, error) {
+	idx, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := os.Stat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if src.ModTime().After(idx.ModTime()) {
+		return nil, ErrStale
+	}
+
+	return Load(path)
+}
@@ -0,0 +1,289 @@
+package index
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+var docs = []Document{
+	{Num: 1, Published: day(2006, 1, 1), Title: "Barrel", Alt: "Don't we all.", Transcript: "A boy sits in a barrel which floats in the ocean."},
+	{Num: 2, Published: day(2006, 1, 1), Title: "Petit Trees", Alt: "'Petit' being a reference to Le Petit Prince.", Transcript: "Two trees are growing on opposite sides of a sphere."},
+	{Num: 3, Published: day(2006, 1, 1), Title: "Island", Alt: "Hello, island", Transcript: "A sketch of an island"},
+	{Num: 4, Published: day(2006, 3, 1), Title: "Landscape", Alt: "There's a river flowing through the ocean", Transcript: "A sketch of a landscape with sun on the horizon."},
+	{Num: 5, Published: day(2007, 3, 9), Title: "Blown apart", Alt: "I'd never trust a barrel floating in the ocean.", Transcript: "[[A boy is floating]]"},
+}
+
+func search(t *testing.T, ix *Index, query string) []int {
+	t.Helper()
+
+	results, err := ix.Search(query, BM25)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+
+	nums := []int{}
+	for _, r := range results {
+		nums = append(nums, r.Num)
+	}
+	return nums
+}
+
+func TestStem(t *testing.T) {
+	for word, want := range map[string]string{
+		"caresses":    "caress",
+		"ponies":      "poni",
+		"floats":      "float",
+		"floating":    "float",
+		"hopping":     "hop",
+		"filing":      "file",
+		"agreed":      "agre",
+		"happy":       "happi",
+		"relational":  "relat",
+		"generalize":  "gener",
+		"electricity": "electr",
+		"adjustment":  "adjust",
+		"controlling": "control",
+		"sky":         "sky",
+		"xkcd":        "xkcd",
+	} {
+		if got := stem(word); got != want {
+			t.Errorf("stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestQueries(t *testing.T) {
+	ix := Build(docs)
+
+	for query, want := range map[string][]int{
+		"barrel":                  {1, 5},
+		"BARREL Floated":          {1, 5}, // case and stem don't matter
+		"barrel boy":              {1, 5},
+		"barrel AND sketch":       {},
+		"island OR landscape":     {3, 4},
+		"sketch -island":          {4},
+		"sketch AND NOT island":   {4},
+		"NOT (ocean OR sketch)":   {2},
+		`"boy sits"`:              {1},
+		`"boy floating"`:          {},
+		`"sketch of an island"`:   {3},
+		`"sketch island"`:         {}, // the stopwords still take up room
+		"ocean (barrel OR river)": {1, 4, 5},
+		"prince":                  {2},
+		`"island a sketch"`:       {}, // doesn't span title and alt text
+		"dont":                    {1},
+		"don't":                   {1},
+	} {
+		got := search(t, ix, query)
+
+		sort.Ints(got) // ranking isn't what this is about
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestFieldsAndRanges(t *testing.T) {
+	ix := Build(docs)
+
+	for query, want := range map[string][]int{
+		"title:barrel":              {1},
+		"alt:barrel":                {5},
+		"transcript:barrel":         {1},
+		"title:island alt:island":   {3},
+		`alt:"petit prince"`:        {2},
+		`transcript:"petit prince"`: {},
+		"year:2007":                 {5},
+		"year:2006..2006 barrel":    {1},
+		"num:2..3":                  {2, 3},
+		"num:4..":                   {4, 5},
+		"num:..1 OR num:5":          {1, 5},
+		"ocean -year:2006":          {5},
+		"brrel~":                    {1, 5},
+		"brrel":                     {},
+		"title:brrel~1":             {1},
+		"skech~ landscap~":          {4},
+		"xyz~2":                     {},
+	} {
+		got := search(t, ix, query)
+		sort.Ints(got)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", query, got, want)
+		}
+	}
+}
+
+// An undated comic is in no year, and a phrase in a field has to be in it
+// from start to end, not only start there.
+func TestUndatedAndFieldEdges(t *testing.T) {
+	ix := Build([]Document{
+		{Num: 1, Published: day(2006, 1, 1), Title: "Deep ocean", Alt: "Floats away"},
+		{Num: 2, Title: "Blank", Alt: "No date at all"},
+	})
+
+	for query, want := range map[string][]int{
+		"year:..2010":              {1},
+		"year:0":                   {},
+		"num:..10":                 {1, 2},
+		`"ocean the floats"`:       {1},
+		`title:"ocean the floats"`: {},
+		`alt:"ocean the floats"`:   {},
+		`title:"deep ocean"`:       {1},
+		`alt:"floats away"`:        {1},
+		`transcript:"floats away"`: {},
+	} {
+		got := search(t, ix, query)
+		sort.Ints(got)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		max  int
+		want bool
+	}{
+		{"kitten", "sitting", 3, true},
+		{"kitten", "sitting", 2, false},
+		{"flaw", "lawn", 2, true},
+		{"same", "same", 0, true},
+		{"", "abc", 2, false},
+		{"héllo", "hello", 1, true},
+	} {
+		if got := withinDistance(tc.a, tc.b, tc.max); got != tc.want {
+			t.Errorf("withinDistance(%q, %q, %d) = %t", tc.a, tc.b, tc.max, got)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	mark := func(s string) string { return "[" + s + "]" }
+	text := "A boy sits in a barrel which floats in the ocean. The barrel floats on and on, far away from the shore."
+
+	if got := Snippet(text, []string{"barrel", "float"}, 40, mark); got != "…boy sits in a [barrel] which [floats] in the…" {
+		t.Errorf("got %q", got)
+	}
+
+	if got := Snippet("Floating.", []string{"float"}, 40, mark); got != "[Floating]." {
+		t.Errorf("got %q", got)
+	}
+
+	if got := Snippet(text, []string{"island"}, 40, mark); got != "" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestBadQueries(t *testing.T) {
+	ix := Build(docs)
+
+	for _, query := range []string{"", "the of", "(barrel", "barrel)", "barrel OR", "AND barrel", "year:", "num:a..b", "barrel~x"} {
+		if _, err := ix.Search(query, BM25); err == nil {
+			t.Errorf("%q: no error", query)
+		}
+	}
+
+	if _, err := ix.Search("the", BM25); !errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("got %v, want ErrEmptyQuery", err)
+	}
+}
+
+func TestRanking(t *testing.T) {
+	ix := Build(docs)
+
+	// comic 5 mentions floating twice in a shorter text
+	for _, ranking := range []Ranking{BM25, TFIDF} {
+		results, err := ix.Search("floating boy", ranking)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(results) != 2 || results[0].Num != 5 || results[0].Score <= results[1].Score {
+			t.Errorf("%s: got %+v", ranking, results)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "xkcd.json.index")
+
+	if err := Build(docs).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := search(t, ix, "barrel"); !reflect.DeepEqual(got, search(t, Build(docs), "barrel")) {
+		t.Errorf("loaded index found %v", got)
+	}
+
+	built := 0
+	docsFn := func() ([]Document, error) { built++; return docs, nil }
+
+	// there's no data file, so it never counts as up to date
+	if _, err := LoadOrBuild(path, filepath.Join(dir, "missing.json"), docsFn); err != nil || built != 1 {
+		t.Errorf("got %v after %d builds", err, built)
+	}
+}
+
+func TestOrderAndDates(t *testing.T) {
+	ix := Build(docs)
+
+	results, err := ix.Search("ocean", BM25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nums := func(results []Result) []int {
+		var nums []int
+		for _, r := range results {
+			nums = append(nums, r.Num)
+		}
+		return nums
+	}
+
+	Sort(results, Newest)
+	if got := nums(results); !reflect.DeepEqual(got, []int{5, 4, 1}) {
+		t.Errorf("newest first: got %v", got)
+	}
+
+	Sort(results, Oldest)
+	if got := nums(results); !reflect.DeepEqual(got, []int{1, 4, 5}) {
+		t.Errorf("oldest first: got %v", got)
+	}
+
+	for _, tt := range []struct {
+		since, until time.Time
+		want         []int
+	}{
+		{day(2006, 2, 1), time.Time{}, []int{4, 5}},
+		{time.Time{}, day(2006, 3, 1), []int{1}},
+		{day(2006, 1, 1), day(2006, 3, 2), []int{1, 4}},
+		{time.Time{}, time.Time{}, []int{1, 4, 5}},
+	} {
+		if got := nums(Within(results, tt.since, tt.until)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v to %v: got %v, want %v", tt.since, tt.until, got, tt.want)
+		}
+	}
+
+	if _, err := ParseOrder("sideways"); err == nil {
+		t.Error("no error for an unknown order")
+	}
+}
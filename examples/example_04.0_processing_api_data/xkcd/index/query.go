@@ -0,0 +1,500 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A query is made of words, "quoted phrases", parentheses and the
+// operators AND, OR and NOT, in capitals. Words next to each other must
+// all match, as if joined by AND, and -word is short for NOT word.
+// AND binds tighter than OR.
+//
+//	query = or
+//	or    = and { "OR" and }
+//	and   = unary { [ "AND" ] unary }
+//	unary = ( "NOT" | "-" ) unary | "(" or ")" | [ field ":" ] ( phrase | word [ "~" [ digit ] ] ) | range
+//	field = "title" | "alt" | "transcript"
+//	range = ( "year" | "num" ) ":" [ number ] [ ".." [ number ] ]
+//
+// A word with a ~ matches terms up to that many edits away from it, or a
+// number of edits that depends on its length if there's no digit.
+
+// node is a parsed query. A nil node matches nothing and is dropped by
+// the operators: it's what a query made only of stopwords comes to.
+type node interface {
+	match(ix *Index) docSet
+	// terms lists the terms that count for ranking, the ones not under a NOT
+	terms(ix *Index) []string
+}
+
+type docSet map[int]struct{}
+
+type (
+	termNode struct {
+		term  string
+		field int // anyField, or the only field it may be in
+		fuzz  int // edits allowed
+	}
+
+	phraseNode struct {
+		tokens []token
+		field  int
+	}
+
+	rangeNode struct {
+		field  string // year or num
+		lo, hi int
+	}
+
+	andNode struct{ left, right node }
+	orNode  struct{ left, right node }
+	notNode struct{ n node }
+)
+
+// anyField is the field of a word or phrase that may be anywhere.
+const anyField = -1
+
+var fieldNames = map[string]int{"title": Title, "alt": Alt, "transcript": Transcript}
+
+func (n termNode) match(ix *Index) docSet {
+	set := docSet{}
+
+	for _, term := range n.terms(ix) {
+		for _, p := range ix.Terms[term] {
+			if n.field == anyField || ix.inField(p, n.field) {
+				set[p.Doc] = struct{}{}
+			}
+		}
+	}
+
+	return set
+}
+
+// terms is the term itself, or all the terms close enough to it for a
+// fuzzy match.
+func (n termNode) terms(ix *Index) []string {
+	if n.fuzz == 0 {
+		return []string{n.term}
+	}
+
+	var terms []string
+	for term := range ix.Terms {
+		if withinDistance(n.term, term, n.fuzz) {
+			terms = append(terms, term)
+		}
+	}
+
+	sort.Strings(terms)
+	return terms
+}
+
+// match finds the documents with every term of the phrase at the same
+// distance from the first one as in the query, and all of them in the
+// phrase's field if it has one.
+func (n phraseNode) match(ix *Index) docSet {
+	set := docSet{}
+	first := n.tokens[0]
+
+	for _, p := range ix.Terms[first.term] {
+		doc := ix.Docs[p.Doc]
+
+	positions:
+		for _, pos := range p.Positions {
+			for i, tok := range n.tokens {
+				at := pos + tok.pos - first.pos
+
+				if n.field != anyField && doc.fieldAt(at) != n.field {
+					continue positions
+				}
+
+				if i > 0 && !hasPosition(ix.Terms[tok.term], p.Doc, at) {
+					continue positions
+				}
+			}
+
+			set[p.Doc] = struct{}{}
+			break
+		}
+	}
+
+	return set
+}
+
+func (n phraseNode) terms(*Index) []string {
+	var terms []string
+	for _, tok := range n.tokens {
+		terms = append(terms, tok.term)
+	}
+	return terms
+}
+
+func (n andNode) match(ix *Index) docSet {
+	// a AND NOT b is a minus b, rather than a and everything but b
+	if not, ok := n.right.(notNode); ok {
+		return minus(n.left.match(ix), not.n.match(ix))
+	}
+
+	if not, ok := n.left.(notNode); ok {
+		return minus(n.right.match(ix), not.n.match(ix))
+	}
+
+	left, right := n.left.match(ix), n.right.match(ix)
+	set := docSet{}
+
+	for doc := range left {
+		if _, ok := right[doc]; ok {
+			set[doc] = struct{}{}
+		}
+	}
+
+	return set
+}
+
+func (n andNode) terms(ix *Index) []string { return append(n.left.terms(ix), n.right.terms(ix)...) }
+
+func (n orNode) match(ix *Index) docSet {
+	set := n.left.match(ix)
+	for doc := range n.right.match(ix) {
+		set[doc] = struct{}{}
+	}
+	return set
+}
+
+func (n orNode) terms(ix *Index) []string { return append(n.left.terms(ix), n.right.terms(ix)...) }
+
+func (n notNode) match(ix *Index) docSet {
+	all := docSet{}
+	for doc := range ix.Docs {
+		all[doc] = struct{}{}
+	}
+	return minus(all, n.n.match(ix))
+}
+
+func (n notNode) terms(*Index) []string { return nil }
+
+func (n rangeNode) match(ix *Index) docSet {
+	set := docSet{}
+
+	for i, d := range ix.Docs {
+		v := d.Num
+		if n.field == "year" {
+			// an undated comic is in no year, not in year 0
+			if d.Published.IsZero() {
+				continue
+			}
+			v = d.Year
+		}
+
+		if v >= n.lo && v <= n.hi {
+			set[i] = struct{}{}
+		}
+	}
+
+	return set
+}
+
+func (n rangeNode) terms(*Index) []string { return nil }
+
+func minus(set, drop docSet) docSet {
+	for doc := range drop {
+		delete(set, doc)
+	}
+	return set
+}
+
+// hasPosition says if the term with these postings is at pos in doc.
+func hasPosition(postings []Posting, doc, pos int) bool {
+	i := sort.Search(len(postings), func(i int) bool { return postings[i].Doc >= doc })
+	if i == len(postings) || postings[i].Doc != doc {
+		return false
+	}
+
+	positions := postings[i].Positions
+	j := sort.SearchInts(positions, pos)
+
+	return j < len(positions) && positions[j] == pos
+}
+
+func and(left, right node) node {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	}
+	return andNode{left, right}
+}
+
+func or(left, right node) node {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	}
+	return orNode{left, right}
+}
+
+func not(n node) node {
+	if n == nil {
+		return nil
+	}
+	return notNode{n}
+}
+
+// text is a word or a phrase from the query, analyzed the way the index
+// was. A word the analysis splits up, like "e-mail", is a phrase too, and
+// can't be fuzzy.
+func text(s string, field, fuzz int) node {
+	tokens := analyze(s)
+
+	switch len(tokens) {
+	case 0:
+		return nil
+	case 1:
+		return termNode{tokens[0].term, field, fuzz}
+	}
+
+	return phraseNode{tokens, field}
+}
+
+// word parses what the lexer found that isn't an operator: a word or a
+// phrase, maybe fuzzy, maybe in a field, or a range.
+func word(l string) (node, error) {
+	field := anyField
+
+	if name, rest, ok := strings.Cut(l, ":"); ok {
+		switch name {
+		case "year", "num":
+			return parseRange(name, rest)
+		}
+
+		if f, ok := fieldNames[name]; ok {
+			field, l = f, rest
+		}
+	}
+
+	if strings.HasPrefix(l, `"`) {
+		return text(strings.Trim(l, `"`), field, 0), nil
+	}
+
+	fuzz := 0
+
+	if i := strings.LastIndex(l, "~"); i > 0 {
+		switch n := l[i+1:]; {
+		case n == "":
+			fuzz = autoFuzz(len(l[:i]))
+		case len(n) == 1 && n[0] >= '0' && n[0] <= '9':
+			fuzz = int(n[0] - '0')
+		default:
+			return nil, fmt.Errorf("bad fuzziness in %q, want ~ or ~1 to ~9", l)
+		}
+
+		l = l[:i]
+	}
+
+	return text(l, field, fuzz), nil
+}
+
+// autoFuzz allows more typos in longer words.
+func autoFuzz(n int) int {
+	switch {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	}
+	return 2
+}
+
+// parseRange reads 2010, 2008..2010, 100.. or ..100.
+func parseRange(field, s string) (node, error) {
+	n := rangeNode{field: field, lo: math.MinInt, hi: math.MaxInt}
+
+	from, to, isRange := strings.Cut(s, "..")
+	if !isRange {
+		to = from
+	}
+
+	if from == "" && to == "" {
+		return nil, fmt.Errorf("%s: needs a number or a range", field)
+	}
+
+	var err error
+
+	if from != "" {
+		if n.lo, err = strconv.Atoi(from); err != nil {
+			return nil, fmt.Errorf("%s: bad number %q", field, from)
+		}
+	}
+
+	if to != "" {
+		if n.hi, err = strconv.Atoi(to); err != nil {
+			return nil, fmt.Errorf("%s: bad number %q", field, to)
+		}
+	}
+
+	return n, nil
+}
+
+// parse turns a query into a node, nil if nothing in it can match.
+func parse(query string) (node, error) {
+	p := &parser{lexemes: lex(query)}
+
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() != "" {
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+
+	return n, nil
+}
+
+type parser struct {
+	lexemes []string
+}
+
+func (p *parser) peek() string {
+	if len(p.lexemes) == 0 {
+		return ""
+	}
+	return p.lexemes[0]
+}
+
+func (p *parser) next() string {
+	l := p.peek()
+	if l != "" {
+		p.lexemes = p.lexemes[1:]
+	}
+	return l
+}
+
+func (p *parser) or() (node, error) {
+	n, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "OR" {
+		p.next()
+
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		n = or(n, right)
+	}
+
+	return n, nil
+}
+
+func (p *parser) and() (node, error) {
+	n, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek() {
+		case "", ")", "OR":
+			return n, nil
+		case "AND":
+			p.next()
+		}
+
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		n = and(n, right)
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	switch l := p.next(); {
+	case l == "":
+		return nil, fmt.Errorf("query ends too soon")
+
+	case l == "NOT" || l == "-":
+		n, err := p.unary()
+		return not(n), err
+
+	case l == "(":
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+
+		return n, nil
+
+	case l == ")" || l == "AND" || l == "OR":
+		return nil, fmt.Errorf("unexpected %q", l)
+
+	default:
+		return word(l)
+	}
+}
+
+// lex splits a query into words, "phrases" (quotes kept), parentheses and
+// the - of -word.
+func lex(query string) []string {
+	var (
+		out  []string
+		word strings.Builder
+	)
+
+	flush := func() {
+		if word.Len() > 0 {
+			out = append(out, word.String())
+			word.Reset()
+		}
+	}
+
+	rs := []rune(query)
+
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; {
+		case unicode.IsSpace(r):
+			flush()
+
+		case r == '(' || r == ')':
+			flush()
+			out = append(out, string(r))
+
+		case r == '-' && word.Len() == 0:
+			out = append(out, "-")
+
+		case r == '"' && (word.Len() == 0 || strings.HasSuffix(word.String(), ":")):
+			// a phrase, or a phrase in a field like title:"a b"
+			end := i + 1
+			for end < len(rs) && rs[end] != '"' {
+				end++
+			}
+
+			word.WriteString(`"` + string(rs[i+1:min(end, len(rs))]) + `"`)
+			flush()
+			i = end
+
+		default:
+			word.WriteRune(r)
+		}
+	}
+
+	flush()
+
+	return out
+}
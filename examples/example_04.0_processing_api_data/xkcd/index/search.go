@@ -0,0 +1,191 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Ranking is how results are scored.
+type Ranking int
+
+const (
+	BM25  Ranking = iota // Okapi BM25, the default
+	TFIDF                // log-scaled term frequency times inverse document frequency
+)
+
+// BM25 parameters, the usual ones: k1 saturates term frequency, b is how
+// much long documents are penalized.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+func (r Ranking) String() string {
+	if r == TFIDF {
+		return "tfidf"
+	}
+	return "bm25"
+}
+
+// ParseRanking reads a ranking the way String writes it.
+func ParseRanking(s string) (Ranking, error) {
+	switch s {
+	case "bm25":
+		return BM25, nil
+	case "tfidf":
+		return TFIDF, nil
+	}
+	return 0, fmt.Errorf("unknown ranking %q, want bm25 or tfidf", s)
+}
+
+// Order is how results are sorted.
+type Order int
+
+const (
+	ByScore Order = iota // best first, the default
+	Oldest               // by publication date
+	Newest
+)
+
+func (o Order) String() string {
+	switch o {
+	case Oldest:
+		return "oldest"
+	case Newest:
+		return "newest"
+	}
+	return "score"
+}
+
+// ParseOrder reads an order the way String writes it.
+func ParseOrder(s string) (Order, error) {
+	switch s {
+	case "score":
+		return ByScore, nil
+	case "oldest":
+		return Oldest, nil
+	case "newest":
+		return Newest, nil
+	}
+	return 0, fmt.Errorf("unknown order %q, want score, oldest or newest", s)
+}
+
+// Result is a matching comic, best first.
+type Result struct {
+	Num       int
+	Title     string
+	Published time.Time
+	Score     float64
+	Terms     []string // the terms of the query it contains, for Snippet
+}
+
+// ErrEmptyQuery is what searching for nothing but stopwords returns.
+var ErrEmptyQuery = errors.New("nothing to search for")
+
+// Search returns the comics matching query, best first; comics that score
+// the same, like those matching only NOT terms, come in number order.
+func (ix *Index) Search(query string, ranking Ranking) ([]Result, error) {
+	n, err := parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("bad query: %w", err)
+	}
+
+	if n == nil {
+		return nil, ErrEmptyQuery
+	}
+
+	// a term that's in the query twice still counts once
+	seen := map[string]bool{}
+	var terms []string
+
+	for _, term := range n.terms(ix) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	results := []Result{}
+
+	for doc := range n.match(ix) {
+		d := ix.Docs[doc]
+		r := Result{Num: d.Num, Title: d.Title, Published: d.Published}
+
+		for _, term := range terms {
+			if score := ix.score(term, doc, ranking); score > 0 {
+				r.Score += score
+				r.Terms = append(r.Terms, term)
+			}
+		}
+
+		results = append(results, r)
+	}
+
+	Sort(results, ByScore)
+	return results, nil
+}
+
+// Sort puts results in order. Results that tie, on score or on a day with
+// more than one comic, come in number order.
+func Sort(results []Result, order Order) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+
+		switch {
+		case order == ByScore && a.Score != b.Score:
+			return a.Score > b.Score
+		case order == Oldest && !a.Published.Equal(b.Published):
+			return a.Published.Before(b.Published)
+		case order == Newest && !a.Published.Equal(b.Published):
+			return a.Published.After(b.Published)
+		}
+		return a.Num < b.Num
+	})
+}
+
+// Within keeps the results published on or after since and before until,
+// in the same order. A zero time is no limit; with a limit, results whose
+// date isn't known are dropped.
+func Within(results []Result, since, until time.Time) []Result {
+	if since.IsZero() && until.IsZero() {
+		return results
+	}
+
+	var kept []Result
+	for _, r := range results {
+		switch {
+		case r.Published.IsZero():
+		case !since.IsZero() && r.Published.Before(since):
+		case !until.IsZero() && !r.Published.Before(until):
+		default:
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// score is how much term counts for doc.
+func (ix *Index) score(term string, doc int, ranking Ranking) float64 {
+	postings := ix.Terms[term]
+
+	i := sort.Search(len(postings), func(i int) bool { return postings[i].Doc >= doc })
+	if i == len(postings) || postings[i].Doc != doc {
+		return 0
+	}
+
+	tf := float64(len(postings[i].Positions))
+	df := float64(len(postings))
+	n := float64(len(ix.Docs))
+
+	if ranking == TFIDF {
+		return (1 + math.Log(tf)) * math.Log(n/df)
+	}
+
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	norm := 1 - b + b*float64(ix.Docs[doc].Len)/ix.AvgLen
+
+	return idf * tf * (k1 + 1) / (tf + k1*norm)
+}
@@ -0,0 +1,63 @@
+package index
+
+import "strings"
+
+// Snippet returns about width bytes of text around the first of terms it
+// contains, with every word matching one of them passed through mark, and
+// "…" where it's cut. Terms are index terms, like the ones in a Result.
+// It returns "" when text contains none of them.
+func Snippet(text string, terms []string, width int, mark func(string) string) string {
+	want := map[string]bool{}
+	for _, term := range terms {
+		want[term] = true
+	}
+
+	var hits []span
+
+	for _, sp := range spans(text) {
+		if !stopwords[sp.word] && want[stem(sp.word)] {
+			hits = append(hits, sp)
+		}
+	}
+
+	if len(hits) == 0 {
+		return ""
+	}
+
+	// start a third of the way before the first hit, on a word boundary
+	from := max(0, hits[0].start-width/3)
+	for from > 0 && from < len(text) && text[from-1] != ' ' && text[from-1] != '\n' {
+		from--
+	}
+
+	to := min(len(text), from+width)
+	for to < len(text) && text[to] != ' ' && text[to] != '\n' {
+		to++
+	}
+
+	var b strings.Builder
+
+	if from > 0 {
+		b.WriteString("…")
+	}
+
+	pos := from
+
+	for _, hit := range hits {
+		if hit.start < from || hit.end > to {
+			continue
+		}
+
+		b.WriteString(text[pos:hit.start])
+		b.WriteString(mark(text[hit.start:hit.end]))
+		pos = hit.end
+	}
+
+	b.WriteString(text[pos:to])
+
+	if to < len(text) {
+		b.WriteString("…")
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,278 @@
+package index
+
+// stem reduces an English word to its stem with the Porter algorithm
+// (M.F. Porter, "An algorithm for suffix stripping", 1980), so "jumping",
+// "jumps" and "jumped" all become "jump". Words that aren't plain ASCII
+// lowercase letters, and words of two letters or less, are left alone.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	s := &stemmer{b: []byte(word), k: len(word) - 1}
+	s.step1ab()
+
+	if s.k > 0 {
+		s.step1c()
+		s.step2()
+		s.step3()
+		s.step4()
+		s.step5()
+	}
+
+	return string(s.b[:s.k+1])
+}
+
+// stemmer follows the reference implementation: b[0..k] is the word being
+// stemmed and b[0..j] the stem before the suffix being looked at.
+type stemmer struct {
+	b    []byte
+	j, k int
+}
+
+// cons says if b[i] is a consonant; y is one unless a consonant precedes it.
+func (s *stemmer) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.cons(i-1)
+	}
+	return true
+}
+
+// m counts the vowel-consonant sequences in b[0..j]: with c a run of
+// consonants and v one of vowels, [c](vc){m}[v] has measure m.
+func (s *stemmer) m() int {
+	n, i := 0, 0
+
+	for ; i <= s.j && s.cons(i); i++ {
+	}
+
+	for i <= s.j {
+		for ; i <= s.j && !s.cons(i); i++ {
+		}
+
+		if i > s.j {
+			break
+		}
+
+		for ; i <= s.j && s.cons(i); i++ {
+		}
+
+		n++
+	}
+
+	return n
+}
+
+// vowelInStem says if b[0..j] contains a vowel.
+func (s *stemmer) vowelInStem() bool {
+	for i := 0; i <= s.j; i++ {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleC says if b[i-1..i] is a double consonant.
+func (s *stemmer) doubleC(i int) bool {
+	return i >= 1 && s.b[i] == s.b[i-1] && s.cons(i)
+}
+
+// cvc says if b[i-2..i] is consonant-vowel-consonant and the last one isn't
+// w, x or y. That's where a short word like hop(e) or fil(e) lost its e.
+func (s *stemmer) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends says if b[0..k] ends with suffix, and sets j to just before it.
+func (s *stemmer) ends(suffix string) bool {
+	n := len(suffix)
+
+	if n > s.k+1 || string(s.b[s.k-n+1:s.k+1]) != suffix {
+		return false
+	}
+
+	s.j = s.k - n
+	return true
+}
+
+// setTo replaces b[j+1..k] with r.
+func (s *stemmer) setTo(r string) {
+	s.b = append(s.b[:s.j+1], r...)
+	s.k = s.j + len(r)
+}
+
+// r replaces the suffix with r if the stem has a measure above zero.
+func (s *stemmer) r(r string) {
+	if s.m() > 0 {
+		s.setTo(r)
+	}
+}
+
+// step1ab gets rid of plurals and -ed or -ing: caresses → caress,
+// ponies → poni, meetings → meet, agreed → agree, hopping → hop, filing → file.
+func (s *stemmer) step1ab() {
+	if s.b[s.k] == 's' {
+		switch {
+		case s.ends("sses"):
+			s.k -= 2
+		case s.ends("ies"):
+			s.setTo("i")
+		case s.k >= 1 && s.b[s.k-1] != 's':
+			s.k--
+		}
+	}
+
+	if s.ends("eed") {
+		if s.m() > 0 {
+			s.k--
+		}
+		return
+	}
+
+	if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.k = s.j
+
+		switch {
+		case s.ends("at"):
+			s.setTo("ate")
+		case s.ends("bl"):
+			s.setTo("ble")
+		case s.ends("iz"):
+			s.setTo("ize")
+		case s.doubleC(s.k):
+			switch s.b[s.k] {
+			case 'l', 's', 'z':
+			default:
+				s.k--
+			}
+		default:
+			s.j = s.k
+			if s.m() == 1 && s.cvc(s.k) {
+				s.setTo("e")
+			}
+		}
+	}
+}
+
+// step1c turns a final y into i when there's another vowel in the stem.
+func (s *stemmer) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[s.k] = 'i'
+	}
+}
+
+// step2 maps double suffixes to single ones: -ization → -ize and so on.
+func (s *stemmer) step2() {
+	if s.k < 1 {
+		return
+	}
+
+	for _, rule := range step2Rules[s.b[s.k-1]] {
+		if s.ends(rule[0]) {
+			s.r(rule[1])
+			return
+		}
+	}
+}
+
+var step2Rules = map[byte][][2]string{
+	'a': {{"ational", "ate"}, {"tional", "tion"}},
+	'c': {{"enci", "ence"}, {"anci", "ance"}},
+	'e': {{"izer", "ize"}},
+	'l': {{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}},
+	'o': {{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}},
+	's': {{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}},
+	't': {{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}},
+	'g': {{"logi", "log"}},
+}
+
+// step3 deals with -ic-, -full, -ness and the like.
+func (s *stemmer) step3() {
+	for _, rule := range step3Rules[s.b[s.k]] {
+		if s.ends(rule[0]) {
+			s.r(rule[1])
+			return
+		}
+	}
+}
+
+var step3Rules = map[byte][][2]string{
+	'e': {{"icate", "ic"}, {"ative", ""}, {"alize", "al"}},
+	'i': {{"iciti", "ic"}},
+	'l': {{"ical", "ic"}, {"ful", ""}},
+	's': {{"ness", ""}},
+}
+
+// step4 takes off -ant, -ence and the like when the stem is long enough.
+func (s *stemmer) step4() {
+	if s.k < 1 {
+		return
+	}
+
+	found := false
+
+	for _, suffix := range step4Suffixes[s.b[s.k-1]] {
+		if s.ends(suffix) {
+			found = true
+			break
+		}
+	}
+
+	// -ion only goes after s or t
+	if !found && s.b[s.k-1] == 'o' && s.ends("ion") && s.j >= 0 && (s.b[s.j] == 's' || s.b[s.j] == 't') {
+		found = true
+	}
+
+	if found && s.m() > 1 {
+		s.k = s.j
+	}
+}
+
+var step4Suffixes = map[byte][]string{
+	'a': {"al"},
+	'c': {"ance", "ence"},
+	'e': {"er"},
+	'i': {"ic"},
+	'l': {"able", "ible"},
+	'n': {"ant", "ement", "ment", "ent"},
+	'o': {"ou"},
+	's': {"ism"},
+	't': {"ate", "iti"},
+	'u': {"ous"},
+	'v': {"ive"},
+	'z': {"ize"},
+}
+
+// step5 removes a final -e and turns -ll into -l, on long enough stems.
+func (s *stemmer) step5() {
+	s.j = s.k
+
+	if s.b[s.k] == 'e' {
+		a := s.m()
+		if a > 1 || a == 1 && !s.cvc(s.k-1) {
+			s.k--
+		}
+	}
+
+	if s.b[s.k] == 'l' && s.doubleC(s.k) && s.m() > 1 {
+		s.k--
+	}
+}
@@ -4,16 +4,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
-)
-
-type dollars float64
 
-func (d dollars) String() string {
-	return fmt.Sprintf("$%.2f", d)
-}
+	"inventory/money"
+)
 
-type database map[string]dollars
+type database map[string]money.Money
 
 func (db database) create(w http.ResponseWriter, req *http.Request) {
 	name := req.URL.Query().Get("name")
@@ -24,13 +19,13 @@ func (db database) create(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
-	price, err := strconv.ParseFloat(price_str, 64)
+	price, err := money.Parse(price_str)
 	if err != nil {
 		msg := fmt.Sprintf("Invalid price `%s`", price_str)
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
-	db[name] = dollars(price)
+	db[name] = price
 
 	fmt.Fprintf(w, "Item `%s` created with price `%s`\n", name, db[name])
 }
@@ -67,20 +62,20 @@ func (db database) update(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, msg, http.StatusNotFound)
 		return
 	}
-	price, err := strconv.ParseFloat(price_str, 64)
+	price, err := money.Parse(price_str)
 	if err != nil {
 		msg := fmt.Sprintf("Invalid price `%s`", price_str)
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
-	db[name] = dollars(price)
+	db[name] = price
 	fmt.Fprintf(w, "Item `%s` updated with price `%s`\n", name, db[name])
 }
 
 func main() {
 	db := database{
-		"shoes": 50,
-		"socks": 5,
+		"shoes": money.USD(5000),
+		"socks": money.USD(500),
 	}
 	http.HandleFunc("/create", db.create)
 	http.HandleFunc("/read", db.read)
@@ -1,3 +1,7 @@
 module main.go
 
 go 1.22.6
+
+require inventory v0.0.0
+
+replace inventory => ../example_07.2_inventory_server
@@ -4,20 +4,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
 	"sync"
-)
-
-// NOTE: don't do this in real life
-type dollars float32
 
-func (d dollars) String() string {
-	return fmt.Sprintf("$%.2f", d)
-}
+	"inventory/money"
+)
 
 type database struct {
 	mu   sync.Mutex
-	data map[string]dollars
+	data map[string]money.Money
 }
 
 func (db *database) list(w http.ResponseWriter, req *http.Request) {
@@ -43,14 +37,14 @@ func (db *database) add(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if f64, err := strconv.ParseFloat(price, 32); err != nil {
+	if p, err := money.Parse(price); err != nil {
 		w.WriteHeader(http.StatusBadRequest) // 400
 
 		fmt.Fprintf(w, "invalid price: %q\n", price)
 	} else {
-		db.data[item] = dollars(f64)
+		db.data[item] = p
 
-		fmt.Fprintf(w, "added %s with price %s\n", item, dollars(f64))
+		fmt.Fprintf(w, "added %s with price %s\n", item, p)
 	}
 }
 
@@ -68,14 +62,14 @@ func (db *database) update(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if f64, err := strconv.ParseFloat(price, 32); err != nil {
+	if p, err := money.Parse(price); err != nil {
 		w.WriteHeader(http.StatusBadRequest) // 400
 
 		fmt.Fprintf(w, "invalid price: %q\n", price)
 	} else {
-		db.data[item] = dollars(f64)
+		db.data[item] = p
 
-		fmt.Fprintf(w, "new price %s for %s\n", dollars(f64), item)
+		fmt.Fprintf(w, "new price %s for %s\n", p, item)
 	}
 }
 
@@ -114,9 +108,9 @@ func (db *database) drop(w http.ResponseWriter, req *http.Request) {
 }
 
 var db = database{
-	data: map[string]dollars{
-		"shoes": 50,
-		"socks": 5,
+	data: map[string]money.Money{
+		"shoes": money.USD(5000),
+		"socks": money.USD(500),
 	},
 }
 
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"inventory/money"
+)
+
+// The benchmarks compare the database with the design it replaced, one
+// sync.Mutex held for the whole of every request, writing the response
+// included, under a mix of reads and writes from many goroutines:
+//
+//	go test -run=NONE -bench=. -cpu=1,4,8
+//
+// Each b.N is one request; ns/op is the wall time per request across all
+// the goroutines, so lower means more throughput.
+
+// mutexDatabase is the earlier design, as it was: one sync.Mutex, held
+// by every handler until it has written its response.
+type mutexDatabase struct {
+	mu   sync.Mutex
+	data map[string]money.Money
+}
+
+func (db *mutexDatabase) list(w http.ResponseWriter, req *http.Request) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for item, price := range db.data {
+		fmt.Fprintf(w, "%s: %s\n", item, price)
+	}
+}
+
+func (db *mutexDatabase) update(w http.ResponseWriter, req *http.Request) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	item := req.URL.Query().Get("item")
+	price := req.URL.Query().Get("price")
+
+	if _, ok := db.data[item]; !ok {
+		w.WriteHeader(http.StatusNotFound) // 404
+
+		fmt.Fprintf(w, "no such item: %q\n", item)
+		return
+	}
+
+	if p, err := money.Parse(price); err != nil {
+		w.WriteHeader(http.StatusBadRequest) // 400
+
+		fmt.Fprintf(w, "invalid price: %q\n", price)
+	} else {
+		db.data[item] = p
+
+		fmt.Fprintf(w, "new price %s for %s\n", p, item)
+	}
+}
+
+func (db *mutexDatabase) fetch(w http.ResponseWriter, req *http.Request) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	item := req.URL.Query().Get("item")
+
+	if _, ok := db.data[item]; !ok {
+		w.WriteHeader(http.StatusNotFound) // 404
+
+		fmt.Fprintf(w, "no such item: %q\n", item)
+		return
+	}
+
+	fmt.Fprintf(w, "item %s has price %s\n", item, db.data[item])
+}
+
+type handlers struct {
+	list, update, fetch http.HandlerFunc
+}
+
+func designs(items map[string]money.Money) map[string]handlers {
+	single := &mutexDatabase{data: maps.Clone(items)}
+	rw := newDatabase(1, items)
+	sharded := newDatabase(shardCount, items)
+
+	return map[string]handlers{
+		"mutex":   {single.list, single.update, single.fetch},
+		"rwmutex": {rw.list, rw.update, rw.fetch},
+		"sharded": {sharded.list, sharded.update, sharded.fetch},
+	}
+}
+
+// slowWriter is a client on a slow network: every write of the response
+// takes a while.
+type slowWriter struct {
+	*httptest.ResponseRecorder
+	delay time.Duration
+}
+
+func (w slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.ResponseRecorder.Write(p)
+}
+
+func BenchmarkMixed(b *testing.B) {
+	items := map[string]money.Money{}
+	fetches := make([]*http.Request, 1000)
+	updates := make([]*http.Request, 1000)
+	list := httptest.NewRequest("GET", "/list", nil)
+
+	// the handlers only read the requests, so they can all share them
+	for i := range 1000 {
+		item := fmt.Sprintf("item%d", i)
+		items[item] = money.USD(int64(i) * 100)
+		fetches[i] = httptest.NewRequest("GET", "/read?item="+item, nil)
+		updates[i] = httptest.NewRequest("GET", "/update?item="+item+"&price=10", nil)
+	}
+
+	for _, load := range []struct {
+		name    string
+		updates int // out of every 100 requests; the others are reads
+		lists   int // out of every 1000 requests
+		delay   time.Duration
+	}{
+		{"reads", 0, 0, 0},
+		{"10%-updates", 10, 0, 0},
+		{"10%-updates-with-lists", 10, 1, 0},
+		{"10%-updates-slow-clients", 10, 0, 50 * time.Microsecond},
+	} {
+		for _, name := range []string{"mutex", "rwmutex", "sharded"} {
+			h := designs(items)[name]
+
+			b.Run(load.name+"/"+name, func(b *testing.B) {
+				var n atomic.Int64
+
+				b.SetParallelism(16) // clients per CPU
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						i := n.Add(1)
+
+						var w http.ResponseWriter = httptest.NewRecorder()
+						if load.delay > 0 {
+							w = slowWriter{httptest.NewRecorder(), load.delay}
+						}
+
+						switch {
+						case load.lists > 0 && i%1000 < int64(load.lists):
+							h.list(w, list)
+						case i%100 < int64(load.updates):
+							h.update(w, updates[i%1000])
+						default:
+							h.fetch(w, fetches[i%1000])
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+// The handlers do the same whatever the design.
+func TestDesigns(t *testing.T) {
+	for name, h := range designs(map[string]money.Money{"shoes": money.USD(5000)}) {
+		rec := httptest.NewRecorder()
+		h.update(rec, httptest.NewRequest("GET", "/update?item=shoes&price=45", nil))
+
+		rec = httptest.NewRecorder()
+		h.fetch(rec, httptest.NewRequest("GET", "/read?item=shoes", nil))
+
+		if got := rec.Body.String(); got != "item shoes has price $45.00\n" {
+			t.Errorf("%s: %q", name, got)
+		}
+	}
+}
@@ -2,123 +2,183 @@ package main
 
 import (
 	"fmt"
+	"hash/maphash"
 	"log"
 	"net/http"
-	"strconv"
 	"sync"
+
+	"inventory/money"
 )
 
-// NOTE: don't do this in real life
-type dollars float32
+// The items are spread over shards, each with its own lock, so requests
+// for items in different shards don't wait for each other. The locks are
+// read-write ones, so requests that only read an item don't wait for each
+// other either, and none is held while the response is written: a slow
+// client mustn't hold up everyone else.
+const shardCount = 16
 
-func (d dollars) String() string {
-	return fmt.Sprintf("$%.2f", d)
+type shard struct {
+	mu   sync.RWMutex
+	data map[string]money.Money
 }
 
 type database struct {
-	mu   sync.Mutex
-	data map[string]dollars
+	seed   maphash.Seed
+	shards []shard
 }
 
-func (db *database) list(w http.ResponseWriter, req *http.Request) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+// newDatabase returns a database of n shards holding items. With one shard
+// it's a map behind a single sync.RWMutex.
+func newDatabase(n int, items map[string]money.Money) *database {
+	db := &database{seed: maphash.MakeSeed(), shards: make([]shard, n)}
+
+	for i := range db.shards {
+		db.shards[i].data = map[string]money.Money{}
+	}
+
+	for item, price := range items {
+		db.shard(item).data[item] = price
+	}
+
+	return db
+}
+
+func (db *database) shard(item string) *shard {
+	return &db.shards[maphash.String(db.seed, item)%uint64(len(db.shards))]
+}
+
+// all copies the items out one shard at a time, so it's not a snapshot of
+// the whole database at one moment, but every item is as it was at some
+// moment of the copy.
+func (db *database) all() map[string]money.Money {
+	items := map[string]money.Money{}
 
-	for item, price := range db.data {
+	for i := range db.shards {
+		s := &db.shards[i]
+
+		s.mu.RLock()
+		for item, price := range s.data {
+			items[item] = price
+		}
+		s.mu.RUnlock()
+	}
+
+	return items
+}
+
+func (db *database) list(w http.ResponseWriter, req *http.Request) {
+	for item, price := range db.all() {
 		fmt.Fprintf(w, "%s: %s\n", item, price)
 	}
 }
 
 func (db *database) add(w http.ResponseWriter, req *http.Request) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	item := req.URL.Query().Get("item")
 	price := req.URL.Query().Get("price")
 
-	if _, ok := db.data[item]; ok {
-		w.WriteHeader(http.StatusBadRequest) // 404
+	p, err := money.Parse(price)
 
-		fmt.Fprintf(w, "duplicate item: %q\n", item)
-		return
+	s := db.shard(item)
+	s.mu.Lock()
+
+	_, exists := s.data[item]
+	if !exists && err == nil {
+		s.data[item] = p
 	}
 
-	if f64, err := strconv.ParseFloat(price, 32); err != nil {
+	s.mu.Unlock()
+
+	switch {
+	case exists:
+		w.WriteHeader(http.StatusBadRequest) // 400
+
+		fmt.Fprintf(w, "duplicate item: %q\n", item)
+
+	case err != nil:
 		w.WriteHeader(http.StatusBadRequest) // 400
 
 		fmt.Fprintf(w, "invalid price: %q\n", price)
-	} else {
-		db.data[item] = dollars(f64)
 
-		fmt.Fprintf(w, "added %s with price %s\n", item, dollars(f64))
+	default:
+		fmt.Fprintf(w, "added %s with price %s\n", item, p)
 	}
 }
 
 func (db *database) update(w http.ResponseWriter, req *http.Request) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	item := req.URL.Query().Get("item")
 	price := req.URL.Query().Get("price")
 
-	if _, ok := db.data[item]; !ok {
+	p, err := money.Parse(price)
+
+	s := db.shard(item)
+	s.mu.Lock()
+
+	_, exists := s.data[item]
+	if exists && err == nil {
+		s.data[item] = p
+	}
+
+	s.mu.Unlock()
+
+	switch {
+	case !exists:
 		w.WriteHeader(http.StatusNotFound) // 404
 
 		fmt.Fprintf(w, "no such item: %q\n", item)
-		return
-	}
 
-	if f64, err := strconv.ParseFloat(price, 32); err != nil {
+	case err != nil:
 		w.WriteHeader(http.StatusBadRequest) // 400
 
 		fmt.Fprintf(w, "invalid price: %q\n", price)
-	} else {
-		db.data[item] = dollars(f64)
 
-		fmt.Fprintf(w, "new price %s for %s\n", dollars(f64), item)
+	default:
+		fmt.Fprintf(w, "new price %s for %s\n", p, item)
 	}
 }
 
 func (db *database) fetch(w http.ResponseWriter, req *http.Request) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	item := req.URL.Query().Get("item")
 
-	if _, ok := db.data[item]; !ok {
+	s := db.shard(item)
+	s.mu.RLock()
+	price, ok := s.data[item]
+	s.mu.RUnlock()
+
+	if !ok {
 		w.WriteHeader(http.StatusNotFound) // 404
 
 		fmt.Fprintf(w, "no such item: %q\n", item)
 		return
 	}
 
-	fmt.Fprintf(w, "item %s has price %s\n", item, db.data[item])
+	fmt.Fprintf(w, "item %s has price %s\n", item, price)
 }
 
 func (db *database) drop(w http.ResponseWriter, req *http.Request) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	item := req.URL.Query().Get("item")
 
-	if _, ok := db.data[item]; !ok {
+	s := db.shard(item)
+	s.mu.Lock()
+
+	_, ok := s.data[item]
+	delete(s.data, item)
+
+	s.mu.Unlock()
+
+	if !ok {
 		w.WriteHeader(http.StatusNotFound) // 404
 
 		fmt.Fprintf(w, "no such item: %q\n", item)
 		return
 	}
 
-	delete(db.data, item)
-
 	fmt.Fprintf(w, "dropped %s\n", item)
 }
 
-var db = database{
-	data: map[string]dollars{
-		"shoes": 50,
-		"socks": 5,
-	},
-}
+var db = newDatabase(shardCount, map[string]money.Money{
+	"shoes": money.USD(5000),
+	"socks": money.USD(500),
+})
 
 func runServer() {
 	http.HandleFunc("/list", db.list)
@@ -132,4 +192,4 @@ func runServer() {
 
 func main() {
 	runServer()
-}
\ No newline at end of file
+}
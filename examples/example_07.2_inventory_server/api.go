@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"inventory/money"
+)
+
+// item is an inventory item as the REST API reads and writes it. Prices
+// go out as strings such as "12.50 USD" and come in as those or as plain
+// numbers of dollars.
+type item struct {
+	Name    string      `json:"name"`
+	Price   money.Money `json:"price"`
+	Version int64       `json:"version"`
+}
+
+// itemBody is what POST, PUT and PATCH take: everything is optional, so
+// each can say what's missing. A version, as in an item just read, must be
+// the current one, the way an If-Match header would have to match.
+type itemBody struct {
+	Name    *string      `json:"name"`
+	Price   *money.Money `json:"price"`
+	Version *int64       `json:"version"`
+}
+
+const maxBodyBytes = 1 << 20
+
+// GET /items lists the items by name.
+func (db *database) listItems(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, sortedItems(db.items.All()))
+}
+
+// sortedItems returns the items of all, sorted by name.
+func sortedItems(all map[string]entry) []item {
+	items := make([]item, 0, len(all))
+	for name, e := range all {
+		items = append(items, item{name, e.Price, e.Version})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	return items
+}
+
+// POST /items adds an item, which mustn't exist yet.
+func (db *database) createItem(w http.ResponseWriter, req *http.Request) {
+	body, err := readItem(w, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if body.Name == nil {
+		writeError(w, http.StatusBadRequest, errors.New("missing name"))
+		return
+	}
+
+	if err := checkName(*body.Name); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if body.Price == nil {
+		writeError(w, http.StatusBadRequest, errors.New("missing price"))
+		return
+	}
+
+	name := *body.Name
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.items.Get(name); ok {
+		writeError(w, http.StatusConflict, fmt.Errorf("item %q already exists", name))
+		return
+	}
+
+	e, err := db.set(name, *body.Price, entry{}, false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Location", itemURL(name))
+	writeItem(w, http.StatusCreated, name, e)
+}
+
+// GET /items/{name} answers with the item and its ETag.
+func (db *database) getItem(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+
+	e, ok := db.items.Get(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no item %q", name))
+		return
+	}
+
+	writeItem(w, http.StatusOK, name, e)
+}
+
+// PUT /items/{name} sets the price of the item, adding it if it's new.
+// With an If-Match header or a version it only replaces that version.
+func (db *database) putItem(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+
+	body, err := readItem(w, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if body.Name != nil && *body.Name != name {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("name %q in the body isn't %q", *body.Name, name))
+		return
+	}
+
+	if body.Price == nil {
+		writeError(w, http.StatusBadRequest, errors.New("missing price"))
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	old, existed := db.items.Get(name)
+
+	if !precondition(w, req, body, name, old, existed) {
+		return
+	}
+
+	e, err := db.set(name, *body.Price, old, existed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if existed {
+		writeItem(w, http.StatusOK, name, e)
+		return
+	}
+
+	w.Header().Set("Location", itemURL(name))
+	writeItem(w, http.StatusCreated, name, e)
+}
+
+// PATCH /items/{name} changes the fields given of an item that exists.
+// The name can't be changed; the price is the only other field.
+func (db *database) patchItem(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+
+	body, err := readItem(w, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if body.Name != nil && *body.Name != name {
+		writeError(w, http.StatusBadRequest, errors.New("items can't be renamed"))
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e, ok := db.items.Get(name)
+
+	if !precondition(w, req, body, name, e, ok) {
+		return
+	}
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no item %q", name))
+		return
+	}
+
+	if body.Price != nil {
+		if e, err = db.set(name, *body.Price, e, true); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	writeItem(w, http.StatusOK, name, e)
+}
+
+// DELETE /items/{name}, only the version in If-Match if there's one.
+func (db *database) deleteItem(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e, ok := db.items.Get(name)
+
+	if !precondition(w, req, itemBody{}, name, e, ok) {
+		return
+	}
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no item %q", name))
+		return
+	}
+
+	if err := db.items.Delete(name); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// precondition checks req's If-Match header and the version in its body
+// against the item as it is, e if it exists, and answers 412 Precondition
+// Failed if either doesn't match.
+func precondition(w http.ResponseWriter, req *http.Request, body itemBody, name string, e entry, exists bool) bool {
+	if ifMatch(req, e, exists) && (body.Version == nil || exists && *body.Version == e.Version) {
+		return true
+	}
+
+	if exists {
+		w.Header().Set("ETag", e.etag())
+	}
+	writeError(w, http.StatusPreconditionFailed, fmt.Errorf("item %q has changed", name))
+	return false
+}
+
+// writeItem answers with the item and its ETag.
+func writeItem(w http.ResponseWriter, status int, name string, e entry) {
+	w.Header().Set("ETag", e.etag())
+	writeJSON(w, status, item{name, e.Price, e.Version})
+}
+
+// readItem decodes the JSON body of req, which must be one object with no
+// fields but name, price and version. money.Money rejects the bad prices.
+func readItem(w http.ResponseWriter, req *http.Request) (itemBody, error) {
+	var body itemBody
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&body); err != nil {
+		return body, fmt.Errorf("bad JSON: %w", err)
+	}
+
+	if dec.Decode(&struct{}{}) != io.EOF {
+		return body, errors.New("bad JSON: more than one value")
+	}
+
+	return body, nil
+}
+
+// checkName rejects the names that couldn't be used in a URL path.
+func checkName(name string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("bad name %q", name)
+	}
+	return nil
+}
+
+func itemURL(name string) string {
+	return "/items/" + url.PathEscape(name)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"inventory/money"
+)
+
+// send makes a request with body and an If-Match header for each of ifMatch.
+func send(t *testing.T, h http.Handler, method, target, body string, ifMatch ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for _, tag := range ifMatch {
+		req.Header.Add("If-Match", tag)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func testAPI() http.Handler {
+	items, _ := openStorage("memory", "", 0)
+	return (&database{items: items}).routes()
+}
+
+func TestCreateItem(t *testing.T) {
+	h := testAPI()
+
+	rec := send(t, h, "POST", "/items", `{"name": "tie clips", "price": 12.5}`)
+
+	var it item
+	if err := json.Unmarshal(rec.Body.Bytes(), &it); err != nil || rec.Code != http.StatusCreated || it != (item{"tie clips", money.USD(1250), 1}) {
+		t.Fatalf("%d %s", rec.Code, rec.Body)
+	}
+
+	if loc := rec.Header().Get("Location"); loc != "/items/tie%20clips" {
+		t.Errorf("location %q", loc)
+	}
+
+	if rec := send(t, h, "GET", rec.Header().Get("Location"), ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"price":"12.50 USD"`) {
+		t.Errorf("created item: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "POST", "/items", `{"name": "socks", "price": 1}`); rec.Code != http.StatusConflict {
+		t.Errorf("creating socks twice: %d %s", rec.Code, rec.Body)
+	}
+
+	for _, body := range []string{``, `{"name": "hats"}`, `{"price": 1}`, `{"name": "", "price": 1}`, `{"name": "a/b", "price": 1}`,
+		`{"name": "hats", "price": -1}`, `{"name": "hats", "price": 1.005}`, `{"name": "hats", "price": "NaN"}`, `{"name": "hats", "price": 1, "color": "red"}`, `{"name": "hats", "price": 1} {}`} {
+		if rec := send(t, h, "POST", "/items", body); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"error"`) {
+			t.Errorf("%s: %d %s", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestListItems(t *testing.T) {
+	rec := send(t, testAPI(), "GET", "/items", "")
+
+	if rec.Body.String() != `[{"name":"shoes","price":"50.00 USD","version":1},{"name":"socks","price":"5.00 USD","version":1}]`+"\n" ||
+		rec.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("%d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPutItem(t *testing.T) {
+	h := testAPI()
+
+	if rec := send(t, h, "PUT", "/items/socks", `{"price": 6}`); rec.Code != http.StatusOK || rec.Header().Get("Location") != "" {
+		t.Errorf("replacing socks: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "PUT", "/items/hats", `{"name": "hats", "price": "20 USD"}`); rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/items/hats" {
+		t.Errorf("adding hats: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "PUT", "/items/hats", `{"name": "caps", "price": 20}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("another name: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "PUT", "/items/hats", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("no price: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "GET", "/items", ""); rec.Body.String() != `[{"name":"hats","price":"20.00 USD","version":1},{"name":"shoes","price":"50.00 USD","version":1},{"name":"socks","price":"6.00 USD","version":2}]`+"\n" {
+		t.Errorf("got %s", rec.Body)
+	}
+}
+
+func TestPatchItem(t *testing.T) {
+	h := testAPI()
+
+	if rec := send(t, h, "PATCH", "/items/socks", `{"price": 7.1}`); rec.Code != http.StatusOK || rec.Body.String() != `{"name":"socks","price":"7.10 USD","version":2}`+"\n" {
+		t.Errorf("%d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "PATCH", "/items/socks", `{}`); rec.Code != http.StatusOK || rec.Body.String() != `{"name":"socks","price":"7.10 USD","version":2}`+"\n" {
+		t.Errorf("nothing to change: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "PATCH", "/items/hats", `{"price": 7}`); rec.Code != http.StatusNotFound {
+		t.Errorf("no hats: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "PATCH", "/items/socks", `{"name": "stockings"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("renaming: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDeleteItem(t *testing.T) {
+	h := testAPI()
+
+	if rec := send(t, h, "DELETE", "/items/shoes", ""); rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Errorf("%d %s", rec.Code, rec.Body)
+	}
+
+	for _, method := range []string{"GET", "DELETE"} {
+		if rec := send(t, h, method, "/items/shoes", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s after deleting: %d %s", method, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	h := testAPI()
+
+	for _, c := range []struct{ method, target, allow string }{
+		{"DELETE", "/items", "GET, HEAD, POST"},
+		{"POST", "/items/socks", "DELETE, GET, HEAD, PATCH, PUT"},
+	} {
+		rec := send(t, h, c.method, c.target, "")
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != c.allow {
+			t.Errorf("%s %s: %d, allow %q", c.method, c.target, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	h := testAPI()
+
+	// what the old routes do shows up in the new ones, and back
+	send(t, h, "GET", "/create?name=hats&price=20", "")
+	send(t, h, "PATCH", "/items/socks", `{"price": 6}`)
+
+	if code, body := do(t, h, "/list"); code != http.StatusOK || body != "hats: $20.00\nshoes: $50.00\nsocks: $6.00\n" {
+		t.Errorf("%d %q", code, body)
+	}
+}
+
+func TestLegacyPrices(t *testing.T) {
+	h := testAPI()
+
+	for _, price := range []string{"NaN", "-1", "1.005", "1e3", "twelve"} {
+		if code, body := do(t, h, "/update?name=socks&price="+url.QueryEscape(price)); code != http.StatusBadRequest {
+			t.Errorf("%s: %d %q", price, code, body)
+		}
+	}
+
+	if code, body := do(t, h, "/update?name=socks&price="+url.QueryEscape("$0.10")); code != http.StatusOK || body != "Item `socks` updated with price `$0.10`\n" {
+		t.Errorf("%d %q", code, body)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	h := testAPI()
+
+	rec := send(t, h, "GET", "/items/socks", "")
+	tag := rec.Header().Get("ETag")
+	if tag != `"v1-5.00USD"` {
+		t.Fatalf("ETag %q", tag)
+	}
+
+	// two clients read socks and both change the price: the second one
+	// would overwrite the first without knowing it
+	put := func(body string, ifMatch ...string) *httptest.ResponseRecorder {
+		return send(t, h, "PUT", "/items/socks", body, ifMatch...)
+	}
+
+	first := put(`{"price": 6}`, tag)
+	if first.Code != http.StatusOK || first.Header().Get("ETag") != `"v2-6.00USD"` {
+		t.Fatalf("first: %d %s %q", first.Code, first.Body, first.Header().Get("ETag"))
+	}
+
+	if rec := put(`{"price": 7}`, tag); rec.Code != http.StatusPreconditionFailed || rec.Header().Get("ETag") != `"v2-6.00USD"` {
+		t.Errorf("second: %d %s", rec.Code, rec.Body)
+	}
+
+	// the same goes for a version in the body
+	if rec := put(`{"price": 7, "version": 1}`); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("version 1: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := put(`{"price": 7, "version": 2}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"version":3`) {
+		t.Errorf("version 2: %d %s", rec.Code, rec.Body)
+	}
+
+	for _, header := range [][]string{{"*"}, {`"v1-5.00USD", "v4-7.00USD"`}, {`"x"`, `"v5-7.00USD"`}} {
+		if rec := put(`{"price": 7}`, header...); rec.Code != http.StatusOK {
+			t.Errorf("%q: %d %s", header, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := put(`{"price": 7}`, `W/"v6-7.00USD"`); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("weak tag: %d %s", rec.Code, rec.Body)
+	}
+
+	// If-Match asks for an item that exists
+	if rec := send(t, h, "PUT", "/items/hats", `{"price": 20}`, "*"); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("no hats: %d %s", rec.Code, rec.Body)
+	}
+
+	for _, c := range []struct {
+		method, body, ifMatch string
+		want                  int
+	}{
+		{"PATCH", `{"price": 8}`, tag, http.StatusPreconditionFailed},
+		{"PATCH", `{"price": 8}`, `"v6-7.00USD"`, http.StatusOK},
+		{"DELETE", ``, `"v6-7.00USD"`, http.StatusPreconditionFailed},
+		{"DELETE", ``, `"v7-8.00USD"`, http.StatusNoContent},
+		{"DELETE", ``, `*`, http.StatusPreconditionFailed},
+	} {
+		if rec := send(t, h, c.method, "/items/socks", c.body, c.ifMatch); rec.Code != c.want {
+			t.Errorf("%s If-Match %s: got %d %s", c.method, c.ifMatch, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestLegacyIfMatch(t *testing.T) {
+	h := testAPI()
+
+	tag := send(t, h, "GET", "/read?name=shoes", "").Header().Get("ETag")
+
+	if rec := send(t, h, "GET", "/update?name=shoes&price=55", "", tag); rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"v2-55.00USD"` {
+		t.Errorf("update: %d %s", rec.Code, rec.Body)
+	}
+
+	for _, target := range []string{"/update?name=shoes&price=60", "/delete?name=shoes"} {
+		if rec := send(t, h, "GET", target, "", tag); rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s: %d %s", target, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := send(t, h, "GET", "/read?name=shoes", ""); rec.Body.String() != "shoes: $55.00\n" {
+		t.Errorf("got %q", rec.Body)
+	}
+}
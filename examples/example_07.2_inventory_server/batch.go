@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"inventory/money"
+	"inventory/storage"
+)
+
+// Batches and imports can be a lot bigger than one item.
+const maxBatchBytes = 16 << 20
+
+// operation is one change in a batch: create, update or delete an item.
+// A version, if given, must be the current one, as for PUT.
+type operation struct {
+	Op      string       `json:"op"`
+	Name    string       `json:"name"`
+	Price   *money.Money `json:"price"`
+	Version *int64       `json:"version"`
+}
+
+// result is what became of an operation: the status and item the same
+// request on its own would have got, or its error.
+type result struct {
+	Status int    `json:"status"`
+	Item   *item  `json:"item,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// staged is the changes of a batch or an import so far, on top of the
+// stored items, which stay as they are until all of it is known to work.
+type staged struct {
+	db      *database
+	changes []storage.Change[entry]
+	latest  map[string]*entry // nil once deleted
+}
+
+func (db *database) stage() *staged {
+	return &staged{db: db, latest: map[string]*entry{}}
+}
+
+func (s *staged) get(name string) (entry, bool) {
+	if e, ok := s.latest[name]; ok {
+		if e == nil {
+			return entry{}, false
+		}
+		return *e, true
+	}
+	return s.db.items.Get(name)
+}
+
+// set stages the price of name as the next version, like db.set.
+func (s *staged) set(name string, price money.Money, old entry, exists bool) entry {
+	e := entry{Price: price, Version: 1}
+	if exists {
+		e.Version = old.Version + 1
+	}
+
+	s.latest[name] = &e
+	s.changes = append(s.changes, storage.Change[entry]{Key: name, Value: e})
+	return e
+}
+
+func (s *staged) delete(name string) {
+	s.latest[name] = nil
+	s.changes = append(s.changes, storage.Change[entry]{Key: name, Delete: true})
+}
+
+// commit stores all the staged changes at once.
+func (s *staged) commit() error {
+	if len(s.changes) == 0 {
+		return nil
+	}
+	return s.db.items.Apply(s.changes...)
+}
+
+// POST /batch applies a list of operations all together or not at all,
+// under the one lock, so no other request sees some of them without the
+// others. It answers with a result for each operation, and whether they
+// were applied; if not, the status is that of the first that failed.
+func (db *database) batch(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Operations []operation `json:"operations"`
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBatchBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("bad JSON: %w", err))
+		return
+	}
+
+	if len(body.Operations) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no operations"))
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s := db.stage()
+	results := make([]result, len(body.Operations))
+	status := http.StatusOK
+
+	// every operation is tried, on top of the ones before it, so a client
+	// hears about all that's wrong with a batch at once
+	for i, op := range body.Operations {
+		results[i] = s.do(op)
+
+		if results[i].Status >= 300 && status == http.StatusOK {
+			status = results[i].Status
+		}
+	}
+
+	if status == http.StatusOK {
+		if err := s.commit(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	writeJSON(w, status, map[string]any{"applied": status == http.StatusOK, "results": results})
+}
+
+func (s *staged) do(op operation) result {
+	fail := func(status int, err error) result {
+		return result{Status: status, Error: err.Error()}
+	}
+
+	old, exists := s.get(op.Name)
+
+	switch op.Op {
+	case "create":
+		if err := checkName(op.Name); err != nil {
+			return fail(http.StatusBadRequest, err)
+		}
+		if op.Price == nil {
+			return fail(http.StatusBadRequest, errors.New("missing price"))
+		}
+		if exists {
+			return fail(http.StatusConflict, fmt.Errorf("item %q already exists", op.Name))
+		}
+
+	case "update", "delete":
+		if op.Op == "update" && op.Price == nil {
+			return fail(http.StatusBadRequest, errors.New("missing price"))
+		}
+		if op.Version != nil && (!exists || *op.Version != old.Version) {
+			return fail(http.StatusPreconditionFailed, fmt.Errorf("item %q has changed", op.Name))
+		}
+		if !exists {
+			return fail(http.StatusNotFound, fmt.Errorf("no item %q", op.Name))
+		}
+
+	default:
+		return fail(http.StatusBadRequest, fmt.Errorf("unknown operation %q, want create, update or delete", op.Op))
+	}
+
+	if op.Op == "delete" {
+		s.delete(op.Name)
+		return result{Status: http.StatusNoContent}
+	}
+
+	e := s.set(op.Name, *op.Price, old, exists)
+
+	status := http.StatusOK
+	if op.Op == "create" {
+		status = http.StatusCreated
+	}
+	return result{Status: status, Item: &item{op.Name, e.Price, e.Version}}
+}
+
+// GET /export?format=json|csv downloads every item, sorted by name. The
+// JSON is that of GET /items; the CSV has a header of name, price and
+// version, and names that a spreadsheet would take for a formula are
+// quoted the way csvText does.
+func (db *database) export(w http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	items := sortedItems(db.items.All())
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Disposition", `attachment; filename="inventory.json"`)
+		writeJSON(w, http.StatusOK, items)
+
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="inventory.csv"`)
+
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "price", "version"})
+		for _, it := range items {
+			cw.Write([]string{csvText(it.Name), it.Price.String(), strconv.FormatInt(it.Version, 10)})
+		}
+		cw.Flush()
+
+		// it's too late for an error status, the client gets a short file
+		if err := cw.Error(); err != nil {
+			log.Printf("exporting CSV: %v", err)
+		}
+
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q, want json or csv", format))
+	}
+}
+
+// POST /import sets the price of every item in the body, a CSV file with
+// name and price columns if its Content-Type is text/csv, or else the JSON
+// of GET /items, adding the items that are new. With replace=true the
+// items not in it are deleted. It all happens at once, or not at all if
+// any of it is wrong; versions are ignored, and only go up for the items
+// whose price changes. In a CSV file, a ' at the start of a name is taken
+// off, as a spreadsheet would (see csvText).
+func (db *database) importItems(w http.ResponseWriter, req *http.Request) {
+	var replace bool
+	if v := req.URL.Query().Get("replace"); v != "" {
+		var err error
+		if replace, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("bad replace %q", v))
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, req.Body, maxBatchBytes)
+
+	var (
+		items []item
+		err   error
+	)
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "text/csv") {
+		items, err = readCSV(body)
+	} else {
+		items, err = readJSON(body)
+	}
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, it := range items {
+		if err := checkName(it.Name); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if seen[it.Name] {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("item %q twice", it.Name))
+			return
+		}
+		seen[it.Name] = true
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s := db.stage()
+	var counts struct {
+		Created   int `json:"created"`
+		Updated   int `json:"updated"`
+		Unchanged int `json:"unchanged"`
+		Deleted   int `json:"deleted"`
+	}
+
+	for _, it := range items {
+		old, exists := s.get(it.Name)
+
+		switch {
+		case !exists:
+			counts.Created++
+		case old.Price == it.Price:
+			counts.Unchanged++
+			continue
+		default:
+			counts.Updated++
+		}
+
+		s.set(it.Name, it.Price, old, exists)
+	}
+
+	if replace {
+		for name := range db.items.All() {
+			if !seen[name] {
+				s.delete(name)
+				counts.Deleted++
+			}
+		}
+	}
+
+	if err := s.commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, counts)
+}
+
+// readJSON reads an array of items, which all need a name and a price.
+func readJSON(r io.Reader) ([]item, error) {
+	var bodies []itemBody
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&bodies); err != nil {
+		return nil, fmt.Errorf("bad JSON: %w", err)
+	}
+
+	items := make([]item, len(bodies))
+	for i, body := range bodies {
+		if body.Name == nil || body.Price == nil {
+			return nil, fmt.Errorf("item %d needs a name and a price", i)
+		}
+		items[i] = item{Name: *body.Name, Price: *body.Price}
+	}
+	return items, nil
+}
+
+// readCSV reads the items of a CSV file whose header has a name and a
+// price column; other columns, such as the version of an export, are
+// skipped.
+func readCSV(r io.Reader) ([]item, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("bad CSV: %w", err)
+	}
+
+	column := map[string]int{}
+	for i, name := range header {
+		column[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	nameCol, ok1 := column["name"]
+	priceCol, ok2 := column["price"]
+	if !ok1 || !ok2 {
+		return nil, errors.New("bad CSV: the header needs a name and a price column")
+	}
+
+	var items []item
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad CSV: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		price, err := money.Parse(record[priceCol])
+		if err != nil {
+			return nil, fmt.Errorf("bad CSV on line %d: %w", line, err)
+		}
+
+		items = append(items, item{Name: fromCSVText(record[nameCol]), Price: price})
+	}
+}
+
+// csvText keeps a spreadsheet from running a name such as =HYPERLINK(...)
+// as a formula: a name that starts with =, +, -, @, a tab or a carriage
+// return gets a ' in front, which makes it plain text, the way one would
+// type it in. So does a name that starts with ' already, so fromCSVText
+// can take off the first ' of any name and get it back as it was.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r'", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func fromCSVText(s string) string {
+	s, _ = strings.CutPrefix(s, "'")
+	return s
+}
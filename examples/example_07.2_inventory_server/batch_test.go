@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type batchResponse struct {
+	Applied bool     `json:"applied"`
+	Results []result `json:"results"`
+}
+
+func statuses(t *testing.T, rec *httptest.ResponseRecorder) (bool, []int) {
+	t.Helper()
+
+	var resp batchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+	}
+
+	var got []int
+	for _, r := range resp.Results {
+		got = append(got, r.Status)
+	}
+	return resp.Applied, got
+}
+
+func TestBatch(t *testing.T) {
+	h := testAPI()
+
+	rec := send(t, h, "POST", "/batch", `{"operations": [
+		{"op": "create", "name": "hats", "price": 20},
+		{"op": "update", "name": "hats", "price": "22.50"},
+		{"op": "update", "name": "socks", "price": 6, "version": 1},
+		{"op": "delete", "name": "shoes"}
+	]}`)
+
+	if applied, got := statuses(t, rec); rec.Code != http.StatusOK || !applied || !reflect.DeepEqual(got, []int{201, 200, 200, 204}) {
+		t.Fatalf("%d %s", rec.Code, rec.Body)
+	}
+
+	if !strings.Contains(rec.Body.String(), `"item":{"name":"hats","price":"22.50 USD","version":2}`) {
+		t.Errorf("hats: %s", rec.Body)
+	}
+
+	if code, body := do(t, h, "/list"); code != http.StatusOK || body != "hats: $22.50\nsocks: $6.00\n" {
+		t.Errorf("after the batch: %q", body)
+	}
+}
+
+func TestBatchAllOrNothing(t *testing.T) {
+	h := testAPI()
+
+	rec := send(t, h, "POST", "/batch", `{"operations": [
+		{"op": "update", "name": "shoes", "price": 45},
+		{"op": "create", "name": "socks", "price": 1},
+		{"op": "delete", "name": "hats"},
+		{"op": "update", "name": "shoes", "price": 40, "version": 1},
+		{"op": "update", "name": "shoes"},
+		{"op": "rename", "name": "shoes"},
+		{"op": "create", "name": "a/b", "price": 1}
+	]}`)
+
+	// the status is that of the first to fail, and every one has a result
+	applied, got := statuses(t, rec)
+	if rec.Code != http.StatusConflict || applied || !reflect.DeepEqual(got, []int{200, 409, 404, 412, 400, 400, 400}) {
+		t.Fatalf("%d %s", rec.Code, rec.Body)
+	}
+
+	if code, body := do(t, h, "/list"); code != http.StatusOK || body != "shoes: $50.00\nsocks: $5.00\n" {
+		t.Errorf("after a failed batch: %q", body)
+	}
+
+	for _, body := range []string{``, `{}`, `{"operations": []}`, `{"ops": []}`, `[]`} {
+		if rec := send(t, h, "POST", "/batch", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: %d %s", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+// A batch is one record in the log, and comes back whole after a restart.
+func TestBatchRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.wal")
+
+	items, err := openStorage("wal", path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := (&database{items: items}).routes()
+
+	send(t, h, "POST", "/batch", `{"operations": [{"op": "create", "name": "hats", "price": 20}, {"op": "create", "name": "belts", "price": 15}]}`)
+	items.Close()
+
+	if items, err = openStorage("wal", path, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer items.Close()
+
+	if _, body := do(t, (&database{items: items}).routes(), "/list"); body != "belts: $15.00\nhats: $20.00\n" {
+		t.Errorf("after a restart: %q", body)
+	}
+}
+
+// A batch is on disk once its record is: a compaction it sets off that
+// fails is no reason to tell the client it failed.
+func TestBatchFailedCompaction(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	os.Mkdir(dir, 0o755)
+
+	items, err := openStorage("wal", filepath.Join(dir, "inventory.wal"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer items.Close()
+
+	h := (&database{items: items}).routes()
+	send(t, h, "POST", "/items", `{"name": "socks", "price": 5}`)
+
+	// the log we have open still works, but no new one can be written
+	os.RemoveAll(dir)
+
+	// enough records for one key to compact the log
+	ops := make([]string, 1000)
+	for i := range ops {
+		ops[i] = fmt.Sprintf(`{"op": "update", "name": "socks", "price": %d}`, i)
+	}
+
+	rec := send(t, h, "POST", "/batch", `{"operations": [`+strings.Join(ops, ",")+`]}`)
+	if applied, _ := statuses(t, rec); rec.Code != http.StatusOK || !applied {
+		t.Fatalf("%d %.200s", rec.Code, rec.Body)
+	}
+
+	if _, body := do(t, h, "/list"); body != "socks: $999.00\n" {
+		t.Errorf("after the batch: %q", body)
+	}
+}
+
+func TestExport(t *testing.T) {
+	h := testAPI()
+
+	rec := send(t, h, "GET", "/export?format=csv", "")
+	if rec.Body.String() != "name,price,version\nshoes,$50.00,1\nsocks,$5.00,1\n" ||
+		rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" ||
+		rec.Header().Get("Content-Disposition") != `attachment; filename="inventory.csv"` {
+		t.Errorf("CSV: %q %v", rec.Body, rec.Header())
+	}
+
+	rec = send(t, h, "GET", "/export", "")
+	if rec.Body.String() != send(t, h, "GET", "/items", "").Body.String() {
+		t.Errorf("JSON: %s", rec.Body)
+	}
+
+	if rec := send(t, h, "GET", "/export?format=xml", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("XML: %d", rec.Code)
+	}
+}
+
+// Names a spreadsheet would run as formulas are exported as text, and
+// imported back as they were.
+func TestExportFormulas(t *testing.T) {
+	h := testAPI()
+
+	for _, name := range []string{"=HYPERLINK(\"x\")", "+1", "-1", "@SUM(A1)", "'tis", "plain"} {
+		if rec := send(t, h, "POST", "/items", fmt.Sprintf(`{"name": %q, "price": 1}`, name)); rec.Code != http.StatusCreated {
+			t.Fatalf("%s: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+
+	export := send(t, h, "GET", "/export?format=csv", "").Body.String()
+
+	for _, line := range []string{
+		`"'=HYPERLINK(""x"")",$1.00,1`,
+		"'+1,$1.00,1",
+		"'-1,$1.00,1",
+		"'@SUM(A1),$1.00,1",
+		"''tis,$1.00,1",
+		"plain,$1.00,1",
+	} {
+		if !strings.Contains(export, line+"\n") {
+			t.Errorf("no %s in %q", line, export)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/import?replace=true", strings.NewReader(export))
+	req.Header.Set("Content-Type", "text/csv")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Body.String() != `{"created":0,"updated":0,"unchanged":8,"deleted":0}`+"\n" {
+		t.Errorf("import: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestImport(t *testing.T) {
+	h := testAPI()
+
+	importItems := func(target, contentType, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := importItems("/import", "text/csv", "Name,Price\nsocks,5\nshoes,$45.00\nhats,12.5 USD\n")
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"created":1,"updated":1,"unchanged":1,"deleted":0}`+"\n" {
+		t.Fatalf("CSV: %d %s", rec.Code, rec.Body)
+	}
+
+	// an export goes back in as it came out
+	export := send(t, h, "GET", "/export?format=csv", "").Body.String()
+	if rec := importItems("/import?replace=true", "text/csv", export); rec.Body.String() != `{"created":0,"updated":0,"unchanged":3,"deleted":0}`+"\n" {
+		t.Errorf("export: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = importItems("/import?replace=true", "application/json", `[{"name": "hats", "price": 15}, {"name": "belts", "price": "30.00 USD", "version": 7}]`)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"created":1,"updated":1,"unchanged":0,"deleted":2}`+"\n" {
+		t.Fatalf("JSON: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := send(t, h, "GET", "/export?format=csv", ""); rec.Body.String() != "name,price,version\nbelts,$30.00,1\nhats,$15.00,2\n" {
+		t.Errorf("after the imports: %q", rec.Body)
+	}
+
+	for _, c := range []struct{ target, contentType, body string }{
+		{"/import", "text/csv", "name,price\nsocks,5\nshoes,-1\n"},
+		{"/import", "text/csv", "name,price\nsocks,5\nsocks,6\n"},
+		{"/import", "text/csv", "name,cost\nsocks,5\n"},
+		{"/import", "text/csv", "name,price\nsocks,5,extra\n"},
+		{"/import", "text/csv", ""},
+		{"/import", "application/json", `[{"name": "socks"}]`},
+		{"/import", "application/json", `{"name": "socks", "price": 5}`},
+		{"/import?replace=maybe", "application/json", `[]`},
+	} {
+		if rec := importItems(c.target, c.contentType, c.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: %d %s", c.body, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := send(t, h, "GET", "/export?format=csv", ""); rec.Body.String() != "name,price,version\nbelts,$30.00,1\nhats,$15.00,2\n" {
+		t.Errorf("after the failed imports: %q", rec.Body)
+	}
+}
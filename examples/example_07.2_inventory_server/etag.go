@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// etag identifies the version of an item. It has the price in it as well
+// as the version, since versions start again at 1 when an item is deleted
+// and created again: the tag of a version 1 still matches the new one only
+// if the price is the same, when nothing a client read has changed.
+func (e entry) etag() string {
+	return fmt.Sprintf(`"v%d-%s%s"`, e.Version, e.Price.Amount(), e.Price.Currency())
+}
+
+// ifMatch reports whether the If-Match header of req, if it has one, is
+// met by the item e, or by no item if it doesn't exist. "*" matches any
+// item; weak tags never match, as If-Match compares tags strongly.
+func ifMatch(req *http.Request, e entry, exists bool) bool {
+	header := req.Header.Values("If-Match")
+	if len(header) == 0 {
+		return true
+	}
+
+	if !exists {
+		return false
+	}
+
+	for _, value := range header {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag == "*" || tag == e.etag() {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+module inventory
+
+go 1.22.3
+
+require jsonstore v0.0.0
+
+replace jsonstore => ../example_09.0_generic_json_store
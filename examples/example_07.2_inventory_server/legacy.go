@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"inventory/money"
+)
+
+// The handlers of example 05.1, kept for the clients that use them: they
+// take the name and price from the query string whatever the method, and
+// answer in plain text. New clients use the REST API in api.go. Like it,
+// they give the ETag of an item, and update and delete honor If-Match.
+
+func (db *database) create(w http.ResponseWriter, req *http.Request) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	name := req.URL.Query().Get("name")
+	price_str := req.URL.Query().Get("price")
+
+	if _, ok := db.items.Get(name); ok {
+		msg := fmt.Sprintf("Item `%s` already exists", name)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	price, err := money.Parse(price_str)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid price `%s`", price_str)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	e, err := db.set(name, price, entry{}, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", e.etag())
+	fmt.Fprintf(w, "Item `%s` created with price `%s`\n", name, price)
+}
+
+func (db *database) read(w http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+
+	e, ok := db.items.Get(name)
+	if !ok {
+		http.Error(w, "Item does not exist", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("ETag", e.etag())
+	fmt.Fprintf(w, "%s: %s\n", name, e.Price)
+}
+
+func (db *database) list(w http.ResponseWriter, req *http.Request) {
+	items := db.items.All()
+
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s\n", name, items[name].Price)
+	}
+}
+
+func (db *database) delete(w http.ResponseWriter, req *http.Request) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	name := req.URL.Query().Get("name")
+	e, ok := db.items.Get(name)
+	if !ifMatch(req, e, ok) {
+		msg := fmt.Sprintf("Item `%s` has changed", name)
+		http.Error(w, msg, http.StatusPreconditionFailed)
+		return
+	}
+	if !ok {
+		http.Error(w, "Item does not exist", http.StatusNotFound)
+		return
+	}
+	if err := db.items.Delete(name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Item `%s` deleted\n", name)
+}
+
+func (db *database) update(w http.ResponseWriter, req *http.Request) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	name := req.URL.Query().Get("name")
+	price_str := req.URL.Query().Get("price")
+	old, ok := db.items.Get(name)
+	if !ifMatch(req, old, ok) {
+		msg := fmt.Sprintf("Item `%s` has changed", name)
+		http.Error(w, msg, http.StatusPreconditionFailed)
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf("Item `%s` does not exist", name)
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+	price, err := money.Parse(price_str)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid price `%s`", price_str)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	e, err := db.set(name, price, old, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", e.etag())
+	fmt.Fprintf(w, "Item `%s` updated with price `%s`\n", name, price)
+}
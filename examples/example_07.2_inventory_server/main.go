@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"inventory/money"
+	"inventory/storage"
+)
+
+// database is the inventory of example 05.1, kept in a storage.Storage so
+// it can outlive the process. The mutex makes the check and the change of
+// create, update and delete one step, as in example 07.1.
+type database struct {
+	mu    sync.Mutex
+	items storage.Storage[entry]
+}
+
+// entry is what's kept of an item: its price, and a version that goes up
+// by one whenever the price is set, so clients can tell it has changed.
+type entry struct {
+	Price   money.Money `json:"price"`
+	Version int64       `json:"version"`
+}
+
+// UnmarshalJSON also takes a bare price, as stored before items had
+// versions, for version 1.
+func (e *entry) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		e.Version = 1
+		return json.Unmarshal(data, &e.Price)
+	}
+
+	type plain entry
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// set gives name the price, as the next version of old, or version 1 if
+// there's no item yet. The caller holds db.mu.
+func (db *database) set(name string, price money.Money, old entry, exists bool) (entry, error) {
+	e := entry{Price: price, Version: 1}
+	if exists {
+		e.Version = old.Version + 1
+	}
+	return e, db.items.Put(name, e)
+}
+
+// openStorage opens the kind of storage the -store flag names. Only the
+// in-memory one starts with the shoes and socks of the earlier examples;
+// the others start with what they had last time.
+func openStorage(kind, path string, flush time.Duration) (storage.Storage[entry], error) {
+	switch kind {
+	case "memory":
+		return storage.NewMemory(map[string]entry{"shoes": {money.USD(5000), 1}, "socks": {money.USD(500), 1}}), nil
+	case "snapshot":
+		return storage.OpenSnapshot[entry](path, flush)
+	case "wal":
+		return storage.OpenWAL[entry](path)
+	}
+	return nil, fmt.Errorf("unknown storage %q, want memory, snapshot or wal", kind)
+}
+
+func (db *database) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /items", db.listItems)
+	mux.HandleFunc("POST /items", db.createItem)
+	mux.HandleFunc("GET /items/{name}", db.getItem)
+	mux.HandleFunc("PUT /items/{name}", db.putItem)
+	mux.HandleFunc("PATCH /items/{name}", db.patchItem)
+	mux.HandleFunc("DELETE /items/{name}", db.deleteItem)
+	mux.HandleFunc("POST /batch", db.batch)
+	mux.HandleFunc("GET /export", db.export)
+	mux.HandleFunc("POST /import", db.importItems)
+
+	// the query-string API of the earlier examples
+	mux.HandleFunc("/create", db.create)
+	mux.HandleFunc("/read", db.read)
+	mux.HandleFunc("/update", db.update)
+	mux.HandleFunc("/delete", db.delete)
+	mux.HandleFunc("/list", db.list)
+	return mux
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	kind := flag.String("store", "memory", "where the items are kept: memory, snapshot (a JSON file) or wal (a write-ahead log)")
+	path := flag.String("data", "", "file of the snapshot or the log (default inventory.json or inventory.wal)")
+	flush := flag.Duration("flush", 5*time.Second, "how often the snapshot is written, if anything changed")
+	flag.Parse()
+
+	if *path == "" {
+		*path = "inventory.json"
+		if *kind == "wal" {
+			*path = "inventory.wal"
+		}
+	}
+
+	items, err := openStorage(*kind, *path, *flush)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := &database{items: items}
+	srv := &http.Server{Addr: *addr, Handler: db.routes(), ReadHeaderTimeout: 10 * time.Second}
+
+	// Ctrl-C closes the storage, so the snapshot gets its last write
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	log.Printf("Serving the inventory (%s storage) on %s", *kind, *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Print(err)
+	}
+
+	if err := items.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func do(t *testing.T, h http.Handler, target string) (int, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, kind := range []string{"snapshot", "wal"} {
+		t.Run(kind, func(t *testing.T) {
+			path := filepath.Join(dir, "inventory."+kind)
+
+			items, err := openStorage(kind, path, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			h := (&database{items: items}).routes()
+
+			for _, target := range []string{"/create?name=shoes&price=50", "/create?name=socks&price=5", "/update?name=socks&price=6", "/delete?name=shoes"} {
+				if code, body := do(t, h, target); code != http.StatusOK {
+					t.Fatalf("%s: %d %s", target, code, body)
+				}
+			}
+
+			if code, _ := do(t, h, "/create?name=socks&price=1"); code != http.StatusBadRequest {
+				t.Errorf("creating socks twice: %d", code)
+			}
+
+			if err := items.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// a new server on the same file
+			if items, err = openStorage(kind, path, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			defer items.Close()
+
+			h = (&database{items: items}).routes()
+
+			if _, body := do(t, h, "/list"); body != "socks: $6.00\n" {
+				t.Errorf("after a restart: %q", body)
+			}
+
+			if code, body := do(t, h, "/read?name=shoes"); code != http.StatusNotFound || !strings.Contains(body, "does not exist") {
+				t.Errorf("deleted shoes: %d %q", code, body)
+			}
+
+			// socks were created and updated once
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/items/socks", nil))
+			if tag := rec.Header().Get("ETag"); tag != `"v2-6.00USD"` {
+				t.Errorf("ETag after a restart: %q", tag)
+			}
+		})
+	}
+}
+
+func TestUnknownStorage(t *testing.T) {
+	if _, err := openStorage("cloud", "", 0); err == nil {
+		t.Error("no error for an unknown storage")
+	}
+}
+
+// Files written when prices were float dollars still load.
+func TestFloatPrices(t *testing.T) {
+	dir := t.TempDir()
+
+	snapshot := filepath.Join(dir, "inventory.json")
+	os.WriteFile(snapshot, []byte(`{"shoes": 50, "socks": 5.5}`), 0o644)
+
+	wal := filepath.Join(dir, "inventory.wal")
+	os.WriteFile(wal, []byte(`{"op":"put","key":"shoes","value":50}`+"\n"+`{"op":"put","key":"socks","value":5.5}`+"\n"), 0o644)
+
+	for kind, path := range map[string]string{"snapshot": snapshot, "wal": wal} {
+		items, err := openStorage(kind, path, time.Hour)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+
+		if _, body := do(t, (&database{items: items}).routes(), "/list"); body != "shoes: $50.00\nsocks: $5.50\n" {
+			t.Errorf("%s: %q", kind, body)
+		}
+		items.Close()
+	}
+}
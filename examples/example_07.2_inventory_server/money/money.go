@@ -0,0 +1,277 @@
+// Package money is an exact amount of money: a whole number of cents and
+// the ISO 4217 code of their currency. It's what the inventory examples
+// keep prices in, instead of the float dollars they started with, which
+// can't hold $0.10 exactly and drift once they're added up.
+//
+// Every currency is taken to have two decimals, which is true of the
+// dollar, the euro and the pound but not, say, of the yen.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts parsed without one.
+const DefaultCurrency = "USD"
+
+var (
+	ErrSyntax   = errors.New("not an amount of money")
+	ErrNegative = errors.New("negative amount")
+	ErrDecimals = errors.New("more than two decimals")
+	ErrRange    = errors.New("amount out of range")
+	ErrCurrency = errors.New("different currencies")
+)
+
+// Money is an amount in cents of a currency. The zero Money is zero of no
+// currency in particular, which adds to and compares with any other.
+// Money values are comparable with ==, which is true only for the same
+// amount of the same currency.
+type Money struct {
+	cents    int64
+	currency string
+}
+
+// New returns cents of currency, a three-letter code such as "EUR". It
+// panics if the code isn't one.
+func New(cents int64, currency string) Money {
+	if !isCurrency(currency) {
+		panic(fmt.Sprintf("money: bad currency %q", currency))
+	}
+	return Money{cents, currency}
+}
+
+// USD returns cents of US dollars.
+func USD(cents int64) Money {
+	return Money{cents, "USD"}
+}
+
+// Parse reads an amount such as "12.50", "12.5 EUR", "EUR 12.50" or
+// "$12.50". A dollar sign means US dollars, as does no currency at all.
+// Negative amounts, fractions of a cent, exponents, NaN and infinities are
+// all errors.
+func Parse(s string) (Money, error) {
+	m, err := parse(strings.TrimSpace(s))
+	if err != nil {
+		return Money{}, fmt.Errorf("money: parsing %q: %w", s, err)
+	}
+	return m, nil
+}
+
+func parse(s string) (Money, error) {
+	currency := DefaultCurrency
+
+	switch fields := strings.Fields(s); len(fields) {
+	case 1:
+		s, _ = strings.CutPrefix(s, "$")
+	case 2:
+		// the code may come before the amount or after it
+		amount, code := fields[0], fields[1]
+		if isCurrency(amount) {
+			amount, code = code, amount
+		}
+		if !isCurrency(code) {
+			return Money{}, ErrSyntax
+		}
+		s, currency = amount, code
+	default:
+		return Money{}, ErrSyntax
+	}
+
+	if strings.HasPrefix(s, "-") {
+		return Money{}, ErrNegative
+	}
+
+	units, fraction, dot := strings.Cut(s, ".")
+	if units == "" || !digits(units) || dot && (fraction == "" || !digits(fraction)) {
+		return Money{}, ErrSyntax
+	}
+
+	if len(fraction) > 2 {
+		return Money{}, ErrDecimals
+	}
+
+	whole, err := strconv.ParseInt(units, 10, 64)
+	if err != nil {
+		return Money{}, ErrRange
+	}
+
+	var cents int64
+	if fraction != "" {
+		cents, _ = strconv.ParseInt(fraction+"00"[len(fraction):], 10, 64)
+	}
+
+	if whole > (math.MaxInt64-cents)/100 {
+		return Money{}, ErrRange
+	}
+	return Money{whole*100 + cents, currency}, nil
+}
+
+// MustParse is Parse for amounts known to be good, in tests and the like.
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Cents returns the amount in cents.
+func (m Money) Cents() int64 { return m.cents }
+
+// Currency returns the currency code, "" for the zero Money.
+func (m Money) Currency() string { return m.currency }
+
+func (m Money) IsZero() bool { return m.cents == 0 }
+
+// Add returns m + o, which must be of the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	currency, err := same(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+
+	sum := m.cents + o.cents
+	if (sum > m.cents) != (o.cents > 0) {
+		return Money{}, ErrRange
+	}
+	return Money{sum, currency}, nil
+}
+
+// Sub returns m - o, which must be of the same currency. Unlike a parsed
+// amount, the difference can be negative.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.cents == math.MinInt64 {
+		return Money{}, ErrRange
+	}
+	return m.Add(Money{-o.cents, o.currency})
+}
+
+// Mul returns m times n, for a price by a quantity.
+func (m Money) Mul(n int64) (Money, error) {
+	if m.cents == 0 || n == 0 {
+		return Money{0, m.currency}, nil
+	}
+
+	// the second test is the one overflow the first can't see
+	product := m.cents * n
+	if product/n != m.cents || n == -1 && m.cents == math.MinInt64 {
+		return Money{}, ErrRange
+	}
+	return Money{product, m.currency}, nil
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or more than o,
+// which must be of the same currency.
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := same(m, o); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.cents < o.cents:
+		return -1, nil
+	case m.cents > o.cents:
+		return +1, nil
+	}
+	return 0, nil
+}
+
+// same returns the currency of a and b, that of the other one if either
+// is the zero Money.
+func same(a, b Money) (string, error) {
+	switch {
+	case a.currency == b.currency || b.currency == "":
+		return a.currency, nil
+	case a.currency == "":
+		return b.currency, nil
+	}
+	return "", fmt.Errorf("money: %w: %s and %s", ErrCurrency, a.currency, b.currency)
+}
+
+// Amount formats the amount alone, with two decimals: "12.50".
+func (m Money) Amount() string {
+	sign, cents := "", uint64(m.cents)
+	if m.cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// String formats US dollars as "$12.50" and other currencies as "12.50 EUR".
+func (m Money) String() string {
+	switch m.currency {
+	case "USD", "":
+		if amount, negative := strings.CutPrefix(m.Amount(), "-"); negative {
+			return "-$" + amount
+		}
+		return "$" + m.Amount()
+	}
+	return m.Amount() + " " + m.currency
+}
+
+// MarshalText formats m as "12.50 USD", which Parse reads back.
+func (m Money) MarshalText() ([]byte, error) {
+	currency := m.currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return []byte(m.Amount() + " " + currency), nil
+}
+
+func (m *Money) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// UnmarshalJSON takes a string for Parse, or a number, which is read from
+// its digits and not by way of a float, so 0.1 is exactly ten cents and
+// 0.001 an error. Numbers are in the default currency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v any
+
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil // null leaves m as it is, as with the built-in types
+	case string:
+		return m.UnmarshalText([]byte(v))
+	case json.Number:
+		return m.UnmarshalText([]byte(v))
+	}
+	return fmt.Errorf("money: %s isn't a number or a string", data)
+}
+
+func isCurrency(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
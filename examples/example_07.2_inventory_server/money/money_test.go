@@ -0,0 +1,150 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want Money
+	}{
+		{"12.50", USD(1250)},
+		{"12.5", USD(1250)},
+		{"12", USD(1200)},
+		{"0.01", USD(1)},
+		{"007.10", USD(710)},
+		{"$12.50", USD(1250)},
+		{" 12.50 EUR ", New(1250, "EUR")},
+		{"GBP 3", New(300, "GBP")},
+		{"92233720368547758.07", USD(math.MaxInt64)},
+	} {
+		if got, err := Parse(c.in); err != nil || got != c.want {
+			t.Errorf("%q: got %v, %v, want %v", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want error
+	}{
+		{"", ErrSyntax},
+		{"NaN", ErrSyntax},
+		{"Inf", ErrSyntax},
+		{"1e3", ErrSyntax},
+		{"+1", ErrSyntax},
+		{".5", ErrSyntax},
+		{"5.", ErrSyntax},
+		{"1,000", ErrSyntax},
+		{"$ 5", ErrSyntax},
+		{"5 usd", ErrSyntax},
+		{"5 EUR USD", ErrSyntax},
+		{"-1", ErrNegative},
+		{"$-1.00", ErrNegative},
+		{"-5 EUR", ErrNegative},
+		{"1.005", ErrDecimals},
+		{"0.001", ErrDecimals},
+		{"92233720368547758.08", ErrRange},
+		{"100000000000000000000", ErrRange},
+	} {
+		if got, err := Parse(c.in); !errors.Is(err, c.want) {
+			t.Errorf("%q: got %v, %v, want %v", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, c := range []struct {
+		m         Money
+		str, text string
+	}{
+		{USD(1250), "$12.50", "12.50 USD"},
+		{USD(5), "$0.05", "0.05 USD"},
+		{USD(-1999), "-$19.99", "-19.99 USD"},
+		{New(100000, "EUR"), "1000.00 EUR", "1000.00 EUR"},
+		{Money{}, "$0.00", "0.00 USD"},
+		{USD(math.MinInt64), "-$92233720368547758.08", "-92233720368547758.08 USD"},
+	} {
+		text, _ := c.m.MarshalText()
+		if c.m.String() != c.str || string(text) != c.text {
+			t.Errorf("%#v: got %q and %q", c.m, c.m.String(), text)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	dime := USD(10)
+
+	// what float64 gets wrong
+	sum := Money{}
+	for range 3 {
+		sum, _ = sum.Add(dime)
+	}
+	if sum != USD(30) {
+		t.Errorf("3 × 10¢: got %v", sum)
+	}
+
+	if d, err := USD(500).Sub(USD(1250)); err != nil || d != USD(-750) {
+		t.Errorf("5 - 12.50: got %v, %v", d, err)
+	}
+
+	if p, err := USD(1999).Mul(3); err != nil || p != USD(5997) {
+		t.Errorf("19.99 × 3: got %v, %v", p, err)
+	}
+
+	if c, err := USD(1).Cmp(USD(2)); err != nil || c != -1 {
+		t.Errorf("1¢ vs 2¢: got %d, %v", c, err)
+	}
+
+	if c, err := (Money{}).Cmp(New(1, "EUR")); err != nil || c != -1 {
+		t.Errorf("zero vs 1 EUR cent: got %d, %v", c, err)
+	}
+
+	if _, err := USD(1).Add(New(1, "EUR")); !errors.Is(err, ErrCurrency) {
+		t.Errorf("dollars and euros: got %v", err)
+	}
+
+	if _, err := USD(1).Cmp(New(1, "EUR")); !errors.Is(err, ErrCurrency) {
+		t.Errorf("comparing dollars and euros: got %v", err)
+	}
+
+	for name, f := range map[string]func() (Money, error){
+		"max + 1":  func() (Money, error) { return USD(math.MaxInt64).Add(USD(1)) },
+		"min - 1":  func() (Money, error) { return USD(math.MinInt64).Sub(USD(1)) },
+		"0 - min":  func() (Money, error) { return USD(0).Sub(USD(math.MinInt64)) },
+		"max × 2":  func() (Money, error) { return USD(math.MaxInt64).Mul(2) },
+		"min × -1": func() (Money, error) { return USD(math.MinInt64).Mul(-1) },
+	} {
+		if m, err := f(); !errors.Is(err, ErrRange) {
+			t.Errorf("%s: got %v, %v", name, m, err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct{ A, B, C Money }
+
+	if err := json.Unmarshal([]byte(`{"A": 0.1, "B": "12.50 EUR", "C": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.A != USD(10) || v.B != New(1250, "EUR") || v.C != (Money{}) {
+		t.Errorf("got %+v", v)
+	}
+
+	data, _ := json.Marshal(v)
+	if string(data) != `{"A":"0.10 USD","B":"12.50 EUR","C":"0.00 USD"}` {
+		t.Errorf("got %s", data)
+	}
+
+	for _, in := range []string{`{"A": 0.001}`, `{"A": -1}`, `{"A": 1e2}`, `{"A": true}`, `{"A": "NaN"}`} {
+		if err := json.Unmarshal([]byte(in), &v); err == nil {
+			t.Errorf("%s: no error", in)
+		}
+	}
+}
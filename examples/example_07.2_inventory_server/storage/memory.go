@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"maps"
+	"sync"
+)
+
+// Memory keeps the values in a map, and nowhere else.
+type Memory[V any] struct {
+	mu   sync.RWMutex
+	data map[string]V
+}
+
+// NewMemory returns a storage holding a copy of initial, which may be nil.
+func NewMemory[V any](initial map[string]V) *Memory[V] {
+	data := maps.Clone(initial)
+	if data == nil {
+		data = map[string]V{}
+	}
+	return &Memory[V]{data: data}
+}
+
+func (m *Memory[V]) Get(key string) (V, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.data[key]
+	return v, ok
+}
+
+func (m *Memory[V]) Put(key string, v V) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data[key] = v
+	return nil
+}
+
+func (m *Memory[V]) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.data, key)
+	return nil
+}
+
+func (m *Memory[V]) Apply(changes ...Change[V]) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range changes {
+		if c.Delete {
+			delete(m.data, c.Key)
+		} else {
+			m.data[c.Key] = c.Value
+		}
+	}
+	return nil
+}
+
+func (m *Memory[V]) All() map[string]V {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return maps.Clone(m.data)
+}
+
+func (m *Memory[V]) Close() error { return nil }
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"jsonstore/store"
+)
+
+// Snapshot keeps the values in memory and writes all of them to a JSON
+// file every interval, if anything changed, and when it's closed. A crash
+// loses the changes since the last write.
+type Snapshot[V any] struct {
+	file *store.Store[map[string]V]
+	mem  *Memory[V]
+
+	mu    sync.Mutex // serializes flushes
+	dirty atomic.Bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// OpenSnapshot reads the file at path, if there's one, and flushes it every
+// interval from then on; 0 means only on Close.
+func OpenSnapshot[V any](path string, interval time.Duration) (*Snapshot[V], error) {
+	file := store.New[map[string]V](path, store.Options{Indent: "  "})
+
+	initial, err := file.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	s := &Snapshot[V]{
+		file: file,
+		mem:  NewMemory(initial),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go s.flushEvery(interval)
+	return s, nil
+}
+
+func (s *Snapshot[V]) flushEvery(interval time.Duration) {
+	defer close(s.done)
+
+	if interval <= 0 {
+		<-s.stop
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// a failed flush is tried again next time, and Close reports it
+			s.Flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Snapshot[V]) Get(key string) (V, bool) {
+	return s.mem.Get(key)
+}
+
+// Put and Delete change the map first and mark it dirty after, so a flush
+// in between at worst writes the change twice, never forgets it.
+
+func (s *Snapshot[V]) Put(key string, v V) error {
+	s.mem.Put(key, v)
+	s.markDirty()
+	return nil
+}
+
+func (s *Snapshot[V]) Delete(key string) error {
+	s.mem.Delete(key)
+	s.markDirty()
+	return nil
+}
+
+// Apply changes the map under one lock, so a flush writes all of the
+// changes or none.
+func (s *Snapshot[V]) Apply(changes ...Change[V]) error {
+	s.mem.Apply(changes...)
+	s.markDirty()
+	return nil
+}
+
+func (s *Snapshot[V]) markDirty() {
+	s.dirty.Store(true)
+}
+
+func (s *Snapshot[V]) All() map[string]V {
+	return s.mem.All()
+}
+
+// Flush writes the file now, if anything changed since the last time.
+func (s *Snapshot[V]) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a change made while we write marks it dirty again
+	if !s.dirty.Swap(false) {
+		return nil
+	}
+
+	if err := s.file.Save(s.mem.All()); err != nil {
+		s.dirty.Store(true)
+		return err
+	}
+	return nil
+}
+
+// Close stops the periodic flushes and flushes one last time. Closing
+// again only flushes.
+func (s *Snapshot[V]) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+
+	return s.Flush()
+}
@@ -0,0 +1,43 @@
+// Package storage keeps the inventory's items, by name, in one of three
+// ways:
+//
+//   - Memory keeps them in a map and forgets them when the process ends,
+//     like the examples before this one;
+//   - Snapshot keeps them in memory too, and writes all of them to a JSON
+//     file every so often and when it's closed;
+//   - WAL appends every change to a log before it's applied, replays the
+//     log on startup and compacts it when it's mostly stale entries.
+//
+// All three are a Storage.
+package storage
+
+// Storage is a set of values by key. Implementations are safe for
+// concurrent use, but a read followed by a write isn't atomic: callers that
+// check before they change something need a lock of their own.
+type Storage[V any] interface {
+	// Get returns the value for key, and whether there's one.
+	Get(key string) (V, bool)
+
+	// Put sets the value for key, adding it if it's new.
+	Put(key string, v V) error
+
+	// Delete removes key; there's no error if it isn't there.
+	Delete(key string) error
+
+	// Apply makes all the changes, in order, or none of them: readers and
+	// the files never see some without the others.
+	Apply(changes ...Change[V]) error
+
+	// All returns a copy of everything stored.
+	All() map[string]V
+
+	// Close saves what needs saving; the storage can't be used after that.
+	Close() error
+}
+
+// A Change sets Key to Value, or deletes Key if Delete is set.
+type Change[V any] struct {
+	Key    string
+	Value  V
+	Delete bool
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exercise puts and deletes a few things, the same way for every storage.
+func exercise(t *testing.T, s Storage[float64]) {
+	t.Helper()
+
+	for key, v := range map[string]float64{"shoes": 50, "socks": 5, "hats": 20} {
+		if err := s.Put(key, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Put("socks", 6); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete("hats"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete("gloves"); err != nil {
+		t.Errorf("deleting what isn't there: %v", err)
+	}
+
+	if v, ok := s.Get("socks"); !ok || v != 6 {
+		t.Errorf("got %v, %v", v, ok)
+	}
+
+	if _, ok := s.Get("hats"); ok {
+		t.Error("hats are still there")
+	}
+
+	// in order: the belts come and go again
+	err := s.Apply(Change[float64]{Key: "belts", Value: 30}, Change[float64]{Key: "socks", Value: 6}, Change[float64]{Key: "belts", Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.Get("belts"); ok {
+		t.Error("belts are still there")
+	}
+}
+
+var want = map[string]float64{"shoes": 50, "socks": 6}
+
+func TestMemory(t *testing.T) {
+	initial := map[string]float64{"boots": 80}
+	m := NewMemory(initial)
+
+	m.Delete("boots")
+	if len(initial) != 1 {
+		t.Error("the initial map was changed")
+	}
+
+	exercise(t, m)
+
+	all := m.All()
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("got %v", all)
+	}
+
+	// All is a copy
+	all["shoes"] = 1
+	if v, _ := m.Get("shoes"); v != 50 {
+		t.Errorf("got %v", v)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+
+	s, err := OpenSnapshot[float64](path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exercise(t, s)
+
+	// the ticker writes it without a Close
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), `"socks": 6`) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not flushed: %q", data)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	s.Put("socks", 7)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = OpenSnapshot[float64](path, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got := s.All(); !reflect.DeepEqual(got, map[string]float64{"shoes": 50, "socks": 7}) {
+		t.Errorf("after reopening: %v", got)
+	}
+
+	// a second Close is only another flush
+	s.Close()
+}
+
+func TestWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.wal")
+
+	w, err := OpenWAL[float64](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exercise(t, w)
+	w.Close()
+
+	// a crash halfway through the next record, a batch whose first change
+	// is complete: none of it counts
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"op":"batch","changes":[{"op":"delete","key":"shoes"},{"op":"put","key":"gl`)
+	f.Close()
+
+	if w, err = OpenWAL[float64](path); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := w.All(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after replay: %v", got)
+	}
+
+	// the next record goes where the torn one was
+	w.Put("gloves", 12)
+	w.Close()
+
+	if w, err = OpenWAL[float64](path); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if v, ok := w.Get("gloves"); !ok || v != 12 {
+		t.Errorf("got %v, %v", v, ok)
+	}
+}
+
+func TestWALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.wal")
+
+	w, err := OpenWAL[float64](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.compactAt = 10
+
+	for i := 0; i < 9; i++ {
+		w.Put("socks", float64(i))
+	}
+
+	lines := func() int {
+		data, _ := os.ReadFile(path)
+		return strings.Count(string(data), "\n")
+	}
+
+	if n := lines(); n != 9 {
+		t.Errorf("%d records before compaction", n)
+	}
+
+	// the tenth is one too many for one key
+	w.Put("socks", 9)
+
+	if n := lines(); n != 1 {
+		t.Errorf("%d records after compaction", n)
+	}
+
+	// and the log can still be appended to
+	w.Put("shoes", 50)
+	w.Close()
+
+	if w, err = OpenWAL[float64](path); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if got := w.All(); !reflect.DeepEqual(got, map[string]float64{"socks": 9, "shoes": 50}) {
+		t.Errorf("after compaction: %v", got)
+	}
+}
+
+// A compaction that fails doesn't undo the write that set it off, which
+// is already on disk; Close reports it.
+func TestWALCompactionFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.wal")
+
+	w, err := OpenWAL[float64](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.compactAt = 2
+
+	// the new log is written next to the old one, in a directory that's gone
+	w.path = filepath.Join(t.TempDir(), "gone", "inventory.wal")
+
+	for i := range 3 {
+		if err := w.Put("socks", float64(i)); err != nil {
+			t.Fatalf("put %d: %v", i, err)
+		}
+	}
+
+	if err := w.Close(); err == nil {
+		t.Error("no error from Close after a failed compaction")
+	}
+
+	if w, err = OpenWAL[float64](path); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if v, ok := w.Get("socks"); !ok || v != 2 {
+		t.Errorf("got %v, %v", v, ok)
+	}
+}
+
+func TestWALCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.wal")
+	os.WriteFile(path, []byte("{\"op\":\"put\",\"key\":\"socks\",\"value\":5}\nnot json\n"), 0o644)
+
+	if _, err := OpenWAL[float64](path); err == nil {
+		t.Error("no error for a complete record that isn't JSON")
+	}
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A WAL is compacted once it has at least this many records, and more than
+// twice as many as there are keys.
+const compactAt = 1000
+
+// WAL appends every change to a log file, one JSON record per line, and
+// syncs it before the change is applied in memory. Opening it replays the
+// log; a record left half written by a crash is dropped.
+type WAL[V any] struct {
+	path string
+
+	mu         sync.Mutex
+	log        *os.File
+	size       int64 // of the log, up to the end of the last whole record
+	data       map[string]V
+	records    int // in the log, including the ones later records replaced
+	compactAt  int
+	compactErr error // of the last compaction, which Close reports
+}
+
+// A batch record holds the changes of an Apply, so a crash that cuts it
+// short drops all of them.
+type record[V any] struct {
+	Op      string      `json:"op"` // "put", "delete" or "batch"
+	Key     string      `json:"key,omitempty"`
+	Value   *V          `json:"value,omitempty"`
+	Changes []record[V] `json:"changes,omitempty"`
+}
+
+// OpenWAL replays the log at path, creating it if it doesn't exist.
+func OpenWAL[V any](path string) (*WAL[V], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WAL[V]{path: path, log: f, data: map[string]V{}, compactAt: compactAt}
+
+	if err := w.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := w.maybeCompact(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *WAL[V]) replay() error {
+	r := bufio.NewReader(w.log)
+	offset := int64(0)
+
+	for {
+		line, err := r.ReadBytes('\n')
+
+		if err == io.EOF {
+			w.size = offset
+
+			if len(line) > 0 {
+				// the last record never got its newline: it's incomplete
+				return w.log.Truncate(offset)
+			}
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var rec record[V]
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("%s at %d: %w", w.path, offset, err)
+		}
+
+		if err := w.apply(rec); err != nil {
+			return fmt.Errorf("%s at %d: %w", w.path, offset, err)
+		}
+
+		w.records += rec.size()
+		offset += int64(len(line))
+	}
+}
+
+func (w *WAL[V]) apply(rec record[V]) error {
+	if err := rec.check(); err != nil {
+		return err
+	}
+
+	switch rec.Op {
+	case "put":
+		w.data[rec.Key] = *rec.Value
+	case "delete":
+		delete(w.data, rec.Key)
+	case "batch":
+		for _, c := range rec.Changes {
+			w.apply(c)
+		}
+	}
+	return nil
+}
+
+// check makes sure the whole of rec can be applied before any of it is.
+func (rec record[V]) check() error {
+	switch {
+	case rec.Op == "put" && rec.Value != nil:
+	case rec.Op == "delete":
+	case rec.Op == "batch":
+		for _, c := range rec.Changes {
+			if c.Op == "batch" {
+				return fmt.Errorf("batch in a batch")
+			}
+			if err := c.check(); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("bad record %+v", rec)
+	}
+	return nil
+}
+
+// size is the number of changes in rec.
+func (rec record[V]) size() int {
+	if rec.Op == "batch" {
+		return len(rec.Changes)
+	}
+	return 1
+}
+
+func (w *WAL[V]) Get(key string) (V, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	v, ok := w.data[key]
+	return v, ok
+}
+
+func (w *WAL[V]) Put(key string, v V) error {
+	return w.write(record[V]{Op: "put", Key: key, Value: &v})
+}
+
+func (w *WAL[V]) Delete(key string) error {
+	return w.write(record[V]{Op: "delete", Key: key})
+}
+
+// Apply logs the changes as one record.
+func (w *WAL[V]) Apply(changes ...Change[V]) error {
+	rec := record[V]{Op: "batch", Changes: make([]record[V], len(changes))}
+
+	for i, c := range changes {
+		if c.Delete {
+			rec.Changes[i] = record[V]{Op: "delete", Key: c.Key}
+		} else {
+			rec.Changes[i] = record[V]{Op: "put", Key: c.Key, Value: &c.Value}
+		}
+	}
+
+	return w.write(rec)
+}
+
+// write logs rec, and applies it once it's safely on disk.
+func (w *WAL[V]) write(rec record[V]) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err = w.log.Write(line)
+	if err == nil {
+		err = w.log.Sync()
+	}
+
+	if err != nil {
+		// cut off any part of the line that made it, so the next record
+		// doesn't end up glued to half of this one
+		w.log.Truncate(w.size)
+		return err
+	}
+
+	w.size += int64(len(line))
+	w.apply(rec)
+	w.records += rec.size()
+
+	// the change is made whatever happens now: a failed compaction is
+	// tried again after the next write, and Close reports it
+	w.compactErr = w.maybeCompact()
+	return nil
+}
+
+func (w *WAL[V]) All() map[string]V {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return maps.Clone(w.data)
+}
+
+// Compact replaces the log with one put for each key.
+func (w *WAL[V]) Compact() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.compact()
+}
+
+func (w *WAL[V]) maybeCompact() error {
+	if w.records < w.compactAt || w.records <= 2*len(w.data) {
+		return nil
+	}
+	return w.compact()
+}
+
+func (w *WAL[V]) compact() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for key, v := range w.data {
+		if err := enc.Encode(record[V]{Op: "put", Key: key, Value: &v}); err != nil {
+			return err
+		}
+	}
+
+	// the new log is renamed over the old one, which we still have open
+	if err := replaceLog(w.path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w.log.Close()
+	w.log = f
+	w.size = int64(buf.Len())
+	w.records = len(w.data)
+	return nil
+}
+
+// replaceLog writes a compacted log next to the one at path and renames
+// it over that, so a crash while compacting leaves the old log to replay.
+// It's a stream of records rather than one JSON value, which is why it
+// doesn't go through store.Store like the snapshot does.
+func replaceLog(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// only there if we return before the rename
+	defer os.Remove(tmp.Name())
+
+	// the log itself was opened 0644
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// synced like every record appended to it
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (w *WAL[V]) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.log.Close()
+	if w.compactErr != nil {
+		return w.compactErr
+	}
+	return err
+}
@@ -2,15 +2,19 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"jsonplaceholder/cache"
 )
 
 const url = "https://jsonplaceholder.typicode.com"
@@ -27,13 +31,24 @@ var mark = map[bool]string{
 	true:  "x",
 }
 
-func handler(w http.ResponseWriter, r *http.Request) {
-	// we do it this way so we can ensure we've created the leak
-	// because we're not using the default client with pooling
+// with -cache there's no leak to see: the cache reads and closes every
+// upstream body itself, over the default transport's pooled connections
+var useCache = flag.Bool("cache", false, "answer from a response cache (and don't leak)")
+
+var cached = &http.Client{Transport: responses.Wrap(http.DefaultTransport)}
 
+func handler(w http.ResponseWriter, r *http.Request) {
 	req, _ := http.NewRequest("GET", url+"/todos/"+r.URL.Path[1:], nil)
-	tr := &http.Transport{}
-	cli := &http.Client{Transport: tr}
+	cli := cached
+
+	if !*useCache {
+		// we do it this way so we can ensure we've created the leak
+		// because we're not using the default client with pooling
+
+		tr := &http.Transport{}
+		cli = &http.Client{Transport: tr}
+	}
+
 	resp, err := cli.Do(req)
 
 	if err != nil {
@@ -62,6 +77,8 @@ func handler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	flag.Parse()
+
 	port := os.Getenv("PORT")
 
 	if port == "" {
@@ -85,6 +102,26 @@ var queries = prometheus.NewCounter(prometheus.CounterOpts{
 	Help: "How many queries we've received.",
 })
 
+var responses = cache.New(cache.Options{
+	TTL:                  time.Minute,
+	StaleWhileRevalidate: 5 * time.Minute,
+})
+
 func init() {
 	prometheus.MustRegister(queries)
+
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cache_hits",
+		Help: "How many upstream requests were answered from the cache (fresh or stale).",
+	}, func() float64 {
+		s := responses.Stats()
+		return float64(s.Hits + s.StaleHits)
+	}))
+
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cache_misses",
+		Help: "How many upstream requests went to the server.",
+	}, func() float64 {
+		return float64(responses.Stats().Misses)
+	}))
 }
@@ -1,6 +1,6 @@
 module profile
 
-go 1.14
+go 1.22.3
 
 require (
 	github.com/gorilla/mux v1.8.0
@@ -9,3 +9,16 @@ require (
 	golang.org/x/sys v0.0.0-20210124154548-22da62e12c0c // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
 )
+
+require jsonplaceholder v0.0.0
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/golang/protobuf v1.4.3 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.15.0 // indirect
+)
+
+replace jsonplaceholder => ../../example_03.3_jsonplaceholder_client
@@ -0,0 +1,3 @@
+module jsonstore
+
+go 1.22.3
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"jsonstore/store"
+)
+
+// In this example we persist a list of people through the generic store
+// instead of marshalling, writing, reading and unmarshalling by hand
+// (compare with example_02.0).
+
+type Person struct {
+	Name  string `json:"name"`
+	Age   int    `json:"age"`
+	Email string `json:"email"`
+}
+
+func main() {
+	compress := flag.Bool("gzip", false, "gzip the file")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: go run . [-gzip] <file>")
+		os.Exit(-1)
+	}
+
+	people := store.New[[]Person](flag.Arg(0), store.Options{Gzip: *compress, Indent: "  "})
+
+	updates, cancel := people.Subscribe()
+	defer cancel()
+
+	// Read whatever is there already; a missing file simply means no people yet
+	list, err := people.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintln(os.Stderr, "Error loading people:", err)
+		os.Exit(-1)
+	}
+	fmt.Printf("Loaded %d people from %s\n", len(list), people.Path())
+
+	// Add one more person in a single read-modify-write cycle
+	err = people.Update(func(list *[]Person) error {
+		*list = append(*list, Person{
+			Name:  "John Doe",
+			Age:   30 + len(*list),
+			Email: "johndoe@example.com",
+		})
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error saving people:", err)
+		os.Exit(-1)
+	}
+
+	fmt.Printf("Store changed, it now holds %d people\n", len(<-updates))
+
+	list, err = people.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading people:", err)
+		os.Exit(-1)
+	}
+
+	for _, p := range list {
+		fmt.Printf("%#v\n", p)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package store
+
+import "os"
+
+// On platforms without flock(2) only the in-process mutex of the Store
+// protects the file; concurrent writers in other processes are not excluded.
+
+func lockFile(f *os.File, exclusive bool) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
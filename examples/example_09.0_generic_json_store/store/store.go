@@ -0,0 +1,354 @@
+// Package store persists a single value of any JSON-serialisable type in a
+// file, replacing the marshal / write / read / unmarshal sequence that every
+// example used to write by hand.
+package store
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Options tweak how a Store writes its file. The zero value writes compact,
+// uncompressed JSON with mode 0644.
+type Options struct {
+	Gzip   bool        // compress the file with gzip
+	Indent string      // indent the JSON with this string ("" means compact)
+	Perm   fs.FileMode // permissions of the file, 0644 when zero
+}
+
+// Store reads and writes a value of type T from and to a file.
+// It is safe for concurrent use.
+type Store[T any] struct {
+	path string
+	opts Options
+
+	mu sync.RWMutex // serialises access within this process
+
+	subMu sync.Mutex
+	subs  map[chan T]struct{}
+}
+
+// New returns a store for the file at path. The file does not need to exist.
+func New[T any](path string, opts Options) *Store[T] {
+	if opts.Perm == 0 {
+		opts.Perm = 0o644
+	}
+
+	return &Store[T]{
+		path: path,
+		opts: opts,
+		subs: make(map[chan T]struct{}),
+	}
+}
+
+// Path returns the file the store persists to.
+func (s *Store[T]) Path() string {
+	return s.path
+}
+
+// Load reads and decodes the file. If the file does not exist the zero value
+// is returned together with an error matching fs.ErrNotExist.
+func (s *Store[T]) Load() (T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	unlock, err := s.lock(false)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer unlock()
+
+	return s.read()
+}
+
+// Save replaces the contents of the file with v and notifies subscribers.
+func (s *Store[T]) Save(v T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.write(v); err != nil {
+		return err
+	}
+
+	s.notify(v)
+	return nil
+}
+
+// Update loads the current value (the zero value if there is no file yet),
+// passes it to fn and saves the result, holding the lock for the whole cycle
+// so no other writer can slip in between. Nothing is written if fn fails.
+func (s *Store[T]) Update(fn func(v *T) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v, err := s.read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := fn(&v); err != nil {
+		return err
+	}
+
+	if err := s.write(v); err != nil {
+		return err
+	}
+
+	s.notify(v)
+	return nil
+}
+
+// Subscribe returns a channel receiving every value saved through this store
+// and a function that ends the subscription and closes the channel.
+// A slow subscriber only gets the most recent value.
+func (s *Store[T]) Subscribe() (<-chan T, func()) {
+	ch := make(chan T, 1)
+
+	s.subMu.Lock()
+	s.subs[ch] = struct{}{}
+	s.subMu.Unlock()
+
+	var once sync.Once
+
+	cancel := func() {
+		once.Do(func() {
+			s.subMu.Lock()
+			delete(s.subs, ch)
+			close(ch)
+			s.subMu.Unlock()
+		})
+	}
+
+	return ch, cancel
+}
+
+// Watch polls the file every interval and sends its new contents whenever
+// the modification time or size changes, which also catches writes made by
+// other processes. The channel is closed once ctx is done.
+func (s *Store[T]) Watch(ctx context.Context, interval time.Duration) <-chan T {
+	ch := make(chan T)
+
+	// take the baseline now, so changes made right after Watch returns count
+	var lastMod time.Time
+	var lastSize int64 = -1
+
+	if fi, err := os.Stat(s.path); err == nil {
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			fi, err := os.Stat(s.path)
+			if err != nil || (fi.ModTime().Equal(lastMod) && fi.Size() == lastSize) {
+				continue
+			}
+
+			lastMod, lastSize = fi.ModTime(), fi.Size()
+
+			v, err := s.Load()
+			if err != nil {
+				continue
+			}
+
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// lock takes the advisory lock shared by every process using this file.
+// We lock a separate file because the data file itself gets replaced.
+func (s *Store[T]) lock(exclusive bool) (func(), error) {
+	f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, s.opts.Perm)
+	if err != nil {
+		return nil, fmt.Errorf("store: open lock: %w", err)
+	}
+
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("store: lock %s: %w", s.path, err)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func (s *Store[T]) read() (T, error) {
+	var v T
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return v, err
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+
+	var r io.Reader = br
+
+	// gzip streams start with 0x1f 0x8b, which is never valid JSON,
+	// so we can read both formats whatever the options say
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return v, fmt.Errorf("store: read %s: %w", s.path, err)
+		}
+		defer zr.Close()
+
+		r = zr
+	}
+
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return v, fmt.Errorf("store: decode %s: %w", s.path, err)
+	}
+
+	return v, nil
+}
+
+// write encodes v into a temporary file next to the target and renames it
+// into place, so readers see either the old or the new contents.
+func (s *Store[T]) write(v T) (err error) {
+	dir, base := filepath.Split(s.path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, base+".tmp*")
+	if err != nil {
+		return fmt.Errorf("store: create temp file: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	bw := bufio.NewWriter(tmp)
+
+	var w io.Writer = bw
+	var zw *gzip.Writer
+
+	if s.opts.Gzip {
+		zw = gzip.NewWriter(bw)
+		w = zw
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", s.opts.Indent)
+
+	if err = enc.Encode(v); err != nil {
+		return fmt.Errorf("store: encode %s: %w", s.path, err)
+	}
+
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return fmt.Errorf("store: compress %s: %w", s.path, err)
+		}
+	}
+
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("store: write %s: %w", s.path, err)
+	}
+
+	if err = tmp.Chmod(s.opts.Perm); err != nil {
+		return fmt.Errorf("store: chmod %s: %w", s.path, err)
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("store: sync %s: %w", s.path, err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("store: close %s: %w", s.path, err)
+	}
+
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("store: rename %s: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// notify hands v to every subscriber without blocking, replacing a value
+// the subscriber has not picked up yet. Each one gets a copy of its own,
+// decoded from the JSON of v, so that a subscriber changing the slices or
+// maps in its value changes nothing for the others or for the caller.
+func (s *Store[T]) notify(v T) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	if len(s.subs) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return // write encoded v a moment ago, so this can't happen
+	}
+
+	for ch := range s.subs {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			continue
+		}
+
+		select {
+		case ch <- v:
+			continue
+		default:
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+
+		select {
+		case ch <- v:
+		default:
+		}
+	}
+}
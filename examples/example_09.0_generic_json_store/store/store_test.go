@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type person struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, opts := range []Options{{}, {Gzip: true}, {Indent: "  "}} {
+		path := filepath.Join(t.TempDir(), "person.json")
+		s := New[person](path, opts)
+
+		want := person{Name: "John Doe", Age: 30}
+
+		if err := s.Save(want); err != nil {
+			t.Fatalf("%+v: save: %v", opts, err)
+		}
+
+		got, err := s.Load()
+		if err != nil {
+			t.Fatalf("%+v: load: %v", opts, err)
+		}
+
+		if got != want {
+			t.Errorf("%+v: got %#v, want %#v", opts, got, want)
+		}
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	s := New[[]person](filepath.Join(t.TempDir(), "missing.json"), Options{})
+
+	if _, err := s.Load(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestGzipDetected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "person.json.gz")
+
+	if err := New[person](path, Options{Gzip: true}).Save(person{Name: "Jane"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a store without the gzip option must still read the compressed file
+	got, err := New[person](path, Options{}).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != "Jane" {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter.json")
+
+	// two stores on the same file behave like two processes
+	stores := []*Store[int]{New[int](path, Options{}), New[int](path, Options{})}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func(s *Store[int]) {
+			defer wg.Done()
+
+			if err := s.Update(func(n *int) error { *n++; return nil }); err != nil {
+				t.Error(err)
+			}
+		}(stores[i%2])
+	}
+
+	wg.Wait()
+
+	if n, err := stores[0].Load(); err != nil || n != 50 {
+		t.Errorf("got %d (%v), want 50", n, err)
+	}
+}
+
+func TestUpdateErrorKeepsFile(t *testing.T) {
+	s := New[int](filepath.Join(t.TempDir(), "n.json"), Options{})
+
+	if err := s.Save(1); err != nil {
+		t.Fatal(err)
+	}
+
+	boom := errors.New("boom")
+
+	if err := s.Update(func(n *int) error { *n = 2; return boom }); err != boom {
+		t.Errorf("got %v, want %v", err, boom)
+	}
+
+	if n, _ := s.Load(); n != 1 {
+		t.Errorf("got %d, want 1", n)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := New[int](filepath.Join(t.TempDir(), "n.json"), Options{})
+
+	ch, cancel := s.Subscribe()
+
+	s.Save(1)
+	s.Save(2) // replaces 1, nobody read it yet
+
+	if n := <-ch; n != 2 {
+		t.Errorf("got %d, want 2", n)
+	}
+
+	cancel()
+
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after cancel")
+	}
+
+	s.Save(3) // must not panic on the closed channel
+}
+
+func TestSubscribersGetCopies(t *testing.T) {
+	s := New[[]string](filepath.Join(t.TempDir(), "list.json"), Options{})
+
+	ch1, cancel1 := s.Subscribe()
+	defer cancel1()
+	ch2, cancel2 := s.Subscribe()
+	defer cancel2()
+
+	list := []string{"shoes", "socks"}
+	s.Save(list)
+
+	got1, got2 := <-ch1, <-ch2
+	got1[0] = "hats"
+
+	if got2[0] != "shoes" || list[0] != "shoes" {
+		t.Errorf("one subscriber changed what the others see: %v, %v", got2, list)
+	}
+
+	if v, _ := s.Load(); v[0] != "shoes" {
+		t.Errorf("the file changed: %v", v)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "n.json")
+	s := New[int](path, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := s.Watch(ctx, 10*time.Millisecond)
+
+	// write behind the store's back, as another process would
+	if err := os.WriteFile(path, []byte("42\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case n := <-ch:
+		if n != 42 {
+			t.Errorf("got %d, want 42", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change noticed")
+	}
+}